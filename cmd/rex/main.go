@@ -35,6 +35,18 @@ var commands = []cli.Command{
 			},
 		},
 	},
+	// rex routes inspection.
+	{
+		Name:   "routes",
+		Usage:  "print the route table of the application",
+		Action: Routes,
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "json",
+				Usage: "print the route table in JSON",
+			},
+		},
+	},
 	// helper to generate a secret key.
 	{
 		Name:  "secret",
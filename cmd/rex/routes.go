@@ -0,0 +1,53 @@
+package main
+
+import (
+	"go/build"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/codegangsta/cli"
+
+	"github.com/goanywhere/rex/internal"
+)
+
+// Routes compiles the application & prints its route table (text/JSON).
+func Routes(ctx *cli.Context) {
+	var dir = cwd
+	if len(ctx.Args()) == 1 {
+		dir = ctx.Args()[0]
+	}
+	pkg, err := build.ImportDir(dir, build.AllowBinary)
+	if err != nil || pkg.Name != "main" {
+		log.Fatalf("No buildable Go source files found")
+	}
+
+	binary := filepath.Join(os.TempDir(), "rex-routes")
+	if runtime.GOOS == "windows" {
+		binary += ".exe"
+	}
+	defer os.Remove(binary)
+
+	command := exec.Command("go", "build", "-o", binary)
+	command.Dir = dir
+	command.Stderr = os.Stderr
+	if err := command.Run(); err != nil {
+		log.Fatalf("Failed to compile the application: %v", err)
+	}
+
+	format := "text"
+	if ctx.Bool("json") {
+		format = "json"
+	}
+	// the application prints its routes instead of serving requests under this mode.
+	command = exec.Command(binary)
+	command.Dir = dir
+	command.Env = append(os.Environ(), internal.Routes+"="+format)
+	command.Stdout = os.Stdout
+	command.Stderr = os.Stderr
+	if err := command.Run(); err != nil {
+		log.Fatalf("Failed to inspect the application: %v", err)
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"go/build"
+	"net"
 	"os"
 	"os/exec"
 	"os/signal"
@@ -10,6 +11,7 @@ import (
 	"regexp"
 	"runtime"
 	"syscall"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/codegangsta/cli"
@@ -22,6 +24,9 @@ import (
 	"github.com/goanywhere/fs"
 )
 
+// readyTimeout is the maximum duration to wait for the new process getting ready.
+const readyTimeout = 10 * time.Second
+
 var (
 	port      int
 	watchList = regexp.MustCompile(`\.(go|html|atom|rss|xml)$`)
@@ -31,6 +36,7 @@ type app struct {
 	dir    string
 	binary string
 	args   []string
+	socket *os.File // listening socket shared across restarts.
 
 	task string // script for npm.
 }
@@ -57,6 +63,13 @@ func (self *app) run() (gorun chan bool) {
 	go func() {
 		var proc *os.Process
 		for start := range gorun {
+			var next *os.Process
+			if start {
+				// the previous process keeps serving until the new one gets ready.
+				if next = self.spawn(); next == nil && proc != nil {
+					continue
+				}
+			}
 			if proc != nil {
 				// try soft kill before hard one.
 				if err := proc.Signal(os.Interrupt); err != nil {
@@ -64,25 +77,67 @@ func (self *app) run() (gorun chan bool) {
 				}
 				proc.Wait()
 			}
-			if !start {
-				continue
-			}
-			command := exec.Command(self.binary, fmt.Sprintf("--port=%d", port))
-			command.Dir = self.dir
-			command.Stdout = os.Stdout
-			command.Stderr = os.Stderr
-			if err := command.Start(); err != nil {
-				log.Fatalf("Failed to start the process: %v\n", err)
+			proc = next
+			// browsers reload once the new process took over the socket.
+			if proc != nil {
+				livereload.Reload()
 			}
-			proc = command.Process
 		}
 	}()
 	return
 }
 
+// spawn starts the application process with the listening socket handed off,
+// returns once the process reported ready to accept connections, or nil if
+// the process exited before getting ready.
+func (self *app) spawn() *os.Process {
+	command := exec.Command(self.binary)
+	command.Dir = self.dir
+	command.Env = append(os.Environ(), fmt.Sprintf("PORT=%d", port))
+	command.Stdout = os.Stdout
+	command.Stderr = os.Stderr
+
+	var reader, writer *os.File
+	if self.socket != nil {
+		var err error
+		if reader, writer, err = os.Pipe(); err != nil {
+			log.Fatalf("Failed to start the process: %v\n", err)
+		}
+		defer reader.Close()
+		// fd:3 => listening socket, fd:4 => ready notification.
+		command.ExtraFiles = []*os.File{self.socket, writer}
+		command.Env = append(command.Env, "LISTEN_FDS=1", internal.ReadyFd+"=4")
+	}
+	err := command.Start()
+	if writer != nil {
+		writer.Close()
+	}
+	if err != nil {
+		log.Fatalf("Failed to start the process: %v\n", err)
+	}
+
+	if reader != nil {
+		ready := make(chan error, 1)
+		go func() {
+			_, err := reader.Read(make([]byte, 1))
+			ready <- err
+		}()
+		select {
+		case err := <-ready:
+			if err != nil {
+				log.Errorf("Process exited before getting ready")
+				command.Wait()
+				return nil
+			}
+		case <-time.After(readyTimeout):
+			log.Warnf("Process is not ready after %v", readyTimeout)
+		}
+	}
+	return command.Process
+}
+
 func (self *app) rerun(gorun chan bool) {
 	self.build()
-	livereload.Reload()
 	gorun <- true
 }
 
@@ -99,6 +154,14 @@ func (self *app) Start() {
 		os.Exit(1)
 	}()
 
+	// listen once here, the socket will be inherited by the application server.
+	if listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port)); err == nil {
+		self.socket, _ = listener.(*net.TCPListener).File()
+		listener.Close()
+	} else {
+		log.Fatalf("Failed to listen at %d: %v", port, err)
+	}
+
 	// start waiting the signal to start running.
 	var gorun = self.run()
 	self.build()
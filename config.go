@@ -0,0 +1,138 @@
+package rex
+
+import (
+	"flag"
+	"net"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goanywhere/env"
+)
+
+var (
+	loaded *Config
+	once   sync.Once
+)
+
+// Config holds the settings of an application server.
+type Config struct {
+	// Address is the host to listen on (empty for all interfaces),
+	// or Unix domain socket "unix:/path/to/sock", or inherited "fd:3".
+	Address  string
+	Port     int
+	Debug    bool
+	MaxProcs int
+	// ETag answers the conditional requests of the rendered responses (see Render) with weak ETags.
+	ETag bool
+
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	ShutdownTimeout   time.Duration
+	MaxHeaderBytes    int
+	// MaxConns limits the concurrent connections, 0 for unlimited.
+	MaxConns int
+
+	// TLSCert & TLSKey serve the application over HTTPS once both given.
+	TLSCert string
+	TLSKey  string
+	// RedirectPort redirects plain HTTP requests to HTTPS, 0 to disable.
+	RedirectPort int
+	HTTP2        bool
+}
+
+// NewConfig creates the settings with values from env, falls back to defaults.
+func NewConfig() *Config {
+	return &Config{
+		Address:  env.String("ADDRESS", ""),
+		Port:     env.Int("PORT", 5000),
+		Debug:    env.Bool("DEBUG", true),
+		MaxProcs: env.Int("MAXPROCS", runtime.NumCPU()),
+		ETag:     env.Bool("ETAG", true),
+
+		ReadTimeout:       duration("READ_TIMEOUT", 0),
+		ReadHeaderTimeout: duration("READ_HEADER_TIMEOUT", 0),
+		WriteTimeout:      duration("WRITE_TIMEOUT", 0),
+		IdleTimeout:       duration("IDLE_TIMEOUT", 0),
+		ShutdownTimeout:   duration("SHUTDOWN_TIMEOUT", 30*time.Second),
+		MaxHeaderBytes:    env.Int("MAX_HEADER_BYTES", 0),
+		MaxConns:          env.Int("MAX_CONNS", 0),
+
+		TLSCert:      env.String("TLS_CERT", ""),
+		TLSKey:       env.String("TLS_KEY", ""),
+		RedirectPort: env.Int("REDIRECT_PORT", 0),
+		HTTP2:        env.Bool("HTTP2", true),
+	}
+}
+
+// LoadConfig creates the settings with values from env, overridden by
+// the command line flags, which will be parsed via flag.CommandLine.
+func LoadConfig() *Config {
+	once.Do(func() {
+		loaded = NewConfig()
+		loaded.Flags(flag.CommandLine)
+		flag.Parse()
+	})
+	config := *loaded
+	return &config
+}
+
+// Flags defines the command line flags in the given flag set to override the settings.
+func (self *Config) Flags(set *flag.FlagSet) {
+	set.StringVar(&self.Address, "address", self.Address, "host to run the application server")
+	set.IntVar(&self.Port, "port", self.Port, "port to run the application server")
+	set.BoolVar(&self.Debug, "debug", self.Debug, "flag to toggle debug mode")
+	set.IntVar(&self.MaxProcs, "maxprocs", self.MaxProcs, "maximum cpu processes to run the server")
+	set.BoolVar(&self.ETag, "etag", self.ETag, "flag to toggle weak ETags of the rendered responses")
+
+	set.DurationVar(&self.ReadTimeout, "read-timeout", self.ReadTimeout, "maximum duration to read the entire request")
+	set.DurationVar(&self.ReadHeaderTimeout, "read-header-timeout", self.ReadHeaderTimeout, "maximum duration to read the request headers")
+	set.DurationVar(&self.WriteTimeout, "write-timeout", self.WriteTimeout, "maximum duration to write the response")
+	set.DurationVar(&self.IdleTimeout, "idle-timeout", self.IdleTimeout, "maximum duration to wait for the next request with keep-alives")
+	set.DurationVar(&self.ShutdownTimeout, "shutdown-timeout", self.ShutdownTimeout, "duration to drain active requests before shutdown")
+	set.IntVar(&self.MaxHeaderBytes, "max-header-bytes", self.MaxHeaderBytes, "maximum bytes to read the request headers")
+	set.IntVar(&self.MaxConns, "max-conns", self.MaxConns, "maximum concurrent connections (0 for unlimited)")
+
+	set.StringVar(&self.TLSCert, "tls-cert", self.TLSCert, "certificate file to serve the application over HTTPS")
+	set.StringVar(&self.TLSKey, "tls-key", self.TLSKey, "private key file to serve the application over HTTPS")
+	set.IntVar(&self.RedirectPort, "redirect", self.RedirectPort, "port to redirect plain HTTP requests to HTTPS (0 to disable)")
+	set.BoolVar(&self.HTTP2, "http2", self.HTTP2, "flag to toggle HTTP/2 negotiation over HTTPS")
+}
+
+// Addr returns the address to listen on: "host:port", "unix:/path/to/sock" or "fd:3".
+func (self *Config) Addr() string {
+	if self.host() != self.Address {
+		return self.Address
+	}
+	return net.JoinHostPort(self.Address, strconv.Itoa(self.Port))
+}
+
+// host returns the TCP host to listen on, empty for non-TCP addresses.
+func (self *Config) host() string {
+	if strings.HasPrefix(self.Address, "unix:") || strings.HasPrefix(self.Address, "fd:") {
+		return ""
+	}
+	return self.Address
+}
+
+// Secure reports whether the application should be served over HTTPS.
+func (self *Config) Secure() bool {
+	return self.TLSCert != "" && self.TLSKey != ""
+}
+
+// duration reads the env value as time.Duration, both "30s" & "30" (seconds) are accepted.
+func duration(key string, fallback time.Duration) time.Duration {
+	if value := env.String(key, ""); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil {
+			return time.Duration(seconds) * time.Second
+		}
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return fallback
+}
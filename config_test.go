@@ -0,0 +1,62 @@
+package rex
+
+import (
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/goanywhere/env"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestNewConfig(t *testing.T) {
+	Convey("rex.NewConfig", t, func() {
+		config := NewConfig()
+		So(config.Debug, ShouldBeTrue)
+		So(config.Port, ShouldEqual, 5000)
+		So(config.Addr(), ShouldEqual, ":5000")
+		So(config.ShutdownTimeout, ShouldEqual, 30*time.Second)
+		So(config.Secure(), ShouldBeFalse)
+
+		env.Set("PORT", 9394)
+		env.Set("READ_TIMEOUT", "15")
+		env.Set("WRITE_TIMEOUT", "1m")
+		defer env.Set("PORT", 5000)
+		defer env.Set("READ_TIMEOUT", "")
+		defer env.Set("WRITE_TIMEOUT", "")
+
+		config = NewConfig()
+		So(config.Port, ShouldEqual, 9394)
+		So(config.ReadTimeout, ShouldEqual, 15*time.Second)
+		So(config.WriteTimeout, ShouldEqual, time.Minute)
+	})
+}
+
+func TestConfigFlags(t *testing.T) {
+	Convey("rex.Config.Flags", t, func() {
+		config := NewConfig()
+		set := flag.NewFlagSet("app", flag.ContinueOnError)
+		config.Flags(set)
+
+		err := set.Parse([]string{"--port=9394", "--debug=false", "--address=127.0.0.1", "--shutdown-timeout=5s"})
+		So(err, ShouldBeNil)
+		So(config.Port, ShouldEqual, 9394)
+		So(config.Debug, ShouldBeFalse)
+		So(config.Addr(), ShouldEqual, "127.0.0.1:9394")
+		So(config.ShutdownTimeout, ShouldEqual, 5*time.Second)
+	})
+}
+
+func TestNewWithConfig(t *testing.T) {
+	Convey("rex.NewWithConfig", t, func() {
+		config := NewConfig()
+		config.Port = 9394
+
+		app := NewWithConfig(config)
+		So(app.config.Port, ShouldEqual, 9394)
+		So(New().config.Port, ShouldEqual, 5000)
+
+		user := app.Group("/users")
+		So(user.config, ShouldEqual, config)
+	})
+}
@@ -0,0 +1,81 @@
+package rex
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/goanywhere/rex/internal"
+	"github.com/gorilla/mux"
+)
+
+// Vars returns the route variables for the current request, if any, the route is
+// looked up ahead for the middleware modules running before the route matched.
+func Vars(r *http.Request) map[string]string {
+	if ctx := resolved(r); ctx != nil && ctx.Vars != nil {
+		return ctx.Vars
+	}
+	return mux.Vars(r)
+}
+
+// Param returns the route variable of the current request, empty string if absent.
+func Param(r *http.Request, name string) string {
+	return Vars(r)[name]
+}
+
+// RouteName returns the name of the route matched by the current request, if any,
+// also available to the middleware modules running before the route matched.
+func RouteName(r *http.Request) string {
+	if ctx := resolved(r); ctx != nil {
+		return ctx.Route
+	}
+	return ""
+}
+
+// resolved returns the Context of the request with the matching route resolved ahead (if not yet).
+func resolved(r *http.Request) *internal.Context {
+	ctx := internal.FromRequest(r)
+	if ctx != nil && ctx.Route == "" && ctx.Lookup != nil {
+		ctx.Route, ctx.Vars = ctx.Lookup(r)
+	}
+	return ctx
+}
+
+// Set stores the value under the key for the current request (served by rex), which is
+// visible to all middleware modules & handlers of the request, e.g. the current user.
+func Set(r *http.Request, key, value interface{}) {
+	if ctx := internal.FromRequest(r); ctx != nil {
+		ctx.Set(key, value)
+	}
+}
+
+// Get returns the value stored under the key for the current request, nil if absent.
+func Get(r *http.Request, key interface{}) interface{} {
+	if ctx := internal.FromRequest(r); ctx != nil {
+		return ctx.Get(key)
+	}
+	return nil
+}
+
+// GetString returns the string value stored under the key, empty string if absent.
+func GetString(r *http.Request, key interface{}) string {
+	value, _ := Get(r, key).(string)
+	return value
+}
+
+// GetInt returns the int value stored under the key, 0 if absent.
+func GetInt(r *http.Request, key interface{}) int {
+	value, _ := Get(r, key).(int)
+	return value
+}
+
+// GetBool returns the bool value stored under the key, false if absent.
+func GetBool(r *http.Request, key interface{}) bool {
+	value, _ := Get(r, key).(bool)
+	return value
+}
+
+// GetTime returns the time.Time value stored under the key, zero time if absent.
+func GetTime(r *http.Request, key interface{}) time.Time {
+	value, _ := Get(r, key).(time.Time)
+	return value
+}
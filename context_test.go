@@ -0,0 +1,97 @@
+package rex
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mw "github.com/goanywhere/rex/middleware"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type userKey struct{}
+
+func TestContext(t *testing.T) {
+	app := New()
+	app.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Set(r, userKey{}, "alice")
+			Set(r, "admin", true)
+			Set(r, "visits", 3)
+			next.ServeHTTP(w, r)
+			// values set downstream are visible upstream as well.
+			w.Header().Set("X-Route", RouteName(r))
+		})
+	})
+	app.Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s:%s:%s:%v:%d", Param(r, "id"), RouteName(r), GetString(r, userKey{}), GetBool(r, "admin"), GetInt(r, "visits"))
+	}).Name("user")
+
+	Convey("rex.Param & rex.RouteName", t, func() {
+		request, _ := http.NewRequest("GET", "/users/42", nil)
+		response := httptest.NewRecorder()
+		app.ServeHTTP(response, request)
+		So(response.Body.String(), ShouldEqual, "42:user:alice:true:3")
+		So(response.Header().Get("X-Route"), ShouldEqual, "user")
+	})
+
+	Convey("rex.Param & rex.RouteName (before the route matched)", t, func() {
+		app := New()
+		app.Use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Server", RouteName(r)+":"+Param(r, "team"))
+				next.ServeHTTP(w, r)
+			})
+		})
+		group := app.Group("/teams/{team:int}")
+		group.Use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Group", RouteName(r)+":"+Param(r, "team")+":"+Param(r, "id"))
+				next.ServeHTTP(w, r)
+			})
+		})
+		group.Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, RouteName(r)+":"+Param(r, "team")+":"+Param(r, "id"))
+		}).Name("member")
+
+		request, _ := http.NewRequest("GET", "/teams/7/users/42", nil)
+		response := httptest.NewRecorder()
+		app.ServeHTTP(response, request)
+		So(response.Header().Get("X-Server"), ShouldEqual, "member:7")
+		So(response.Header().Get("X-Group"), ShouldEqual, "member:7:42")
+		So(response.Body.String(), ShouldEqual, "member:7:42")
+
+		request, _ = http.NewRequest("GET", "/missing", nil)
+		response = httptest.NewRecorder()
+		app.ServeHTTP(response, request)
+		So(response.Header().Get("X-Server"), ShouldEqual, ":")
+	})
+
+	Convey("rex.Get (outside rex)", t, func() {
+		request, _ := http.NewRequest("GET", "/", nil)
+		Set(request, "key", "value")
+		So(Get(request, "key"), ShouldBeNil)
+		So(GetString(request, "key"), ShouldBeEmpty)
+		So(GetTime(request, "key").IsZero(), ShouldBeTrue)
+		So(RouteName(request), ShouldBeEmpty)
+		So(Param(request, "id"), ShouldBeEmpty)
+	})
+
+	Convey("rex.Get (published by middleware)", t, func() {
+		app := New()
+		app.Use(mw.Logger, mw.XSRF)
+		app.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			So(time.Since(GetTime(r, mw.StartTimeKey)), ShouldBeLessThan, time.Minute)
+			io.WriteString(w, GetString(r, mw.XSRFTokenKey))
+		})
+
+		request, _ := http.NewRequest("GET", "/", nil)
+		response := httptest.NewRecorder()
+		app.ServeHTTP(response, request)
+		So(response.Body.String(), ShouldNotBeEmpty)
+		So(response.Body.String(), ShouldEqual, response.Header()["X-XSRF-Token"][0])
+	})
+}
@@ -0,0 +1,179 @@
+package rex
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// HTTPError is the error responded with its status code & public message,
+// the internal cause (if any) is logged only.
+type HTTPError struct {
+	Status  int    // HTTP status code.
+	Message string // public message, defaults to the status text.
+	Err     error  // internal cause.
+}
+
+// NewError creates an HTTPError with the status code, public message & internal cause (optional).
+func NewError(status int, message string, cause error) *HTTPError {
+	return &HTTPError{Status: status, Message: message, Err: cause}
+}
+
+func (self *HTTPError) Error() string {
+	if self.Err != nil {
+		return fmt.Sprintf("%d %s: %v", self.Status, self.message(), self.Err)
+	}
+	return fmt.Sprintf("%d %s", self.Status, self.message())
+}
+
+// Cause returns the internal cause of the error.
+func (self *HTTPError) Cause() error {
+	return self.Err
+}
+
+// Unwrap returns the internal cause for errors.Is & errors.As.
+func (self *HTTPError) Unwrap() error {
+	return self.Err
+}
+
+// message returns the public message, defaults to the status text.
+func (self *HTTPError) message() string {
+	if self.Message != "" {
+		return self.Message
+	}
+	return http.StatusText(self.Status)
+}
+
+// StatusError is implemented by the errors responded with their own status code & public
+// details instead of 500 Internal Server Error, e.g. form.Errors (422 Unprocessable Entity).
+type StatusError interface {
+	error
+	StatusCode() int
+	// Details returns the public details of the error, responded as "errors" in JSON.
+	Details() interface{}
+}
+
+// ErrorFunc responds the failed request with the given status code & its cause (if any).
+type ErrorFunc func(w http.ResponseWriter, r *http.Request, status int, err error)
+
+// NotFound sets the handler (http.Handler/http.HandleFunc) for the requests unmatched
+// by the server, defaults to the Error hook with 404 Not Found. Subservers (groups/hosts)
+// inherit the handler unless they set their own.
+func (self *server) NotFound(handler interface{}) {
+	if self.notFound = self.handlerOf(handler); self.notFound == nil {
+		panic("Unsupported handler: NotFound")
+	}
+}
+
+// MethodNotAllowed sets the handler (http.Handler/http.HandleFunc) for the requests
+// matched with other methods only, defaults to the Error hook with 405 Method Not Allowed.
+// Subservers (groups/hosts) inherit the handler unless they set their own.
+func (self *server) MethodNotAllowed(handler interface{}) {
+	if self.methodNotAllowed = self.handlerOf(handler); self.methodNotAllowed == nil {
+		panic("Unsupported handler: MethodNotAllowed")
+	}
+}
+
+// Error sets the hook to respond the failed requests, e.g. JSON errors for an API group
+// while rendered error pages for the site, defaults to RenderError. Subservers (groups/hosts)
+// inherit the hook unless they set their own.
+func (self *server) Error(fn ErrorFunc) {
+	self.onError = fn
+}
+
+// notFoundHandler returns the NotFound handler of the server or its closest ancestor.
+func (self *server) notFoundHandler() http.Handler {
+	for server := self; server != nil; server = server.parent {
+		if server.notFound != nil {
+			return server.notFound
+		}
+	}
+	return self.status(http.StatusNotFound)
+}
+
+// methodNotAllowedHandler returns the MethodNotAllowed handler of the server or its closest ancestor.
+func (self *server) methodNotAllowedHandler() http.Handler {
+	for server := self; server != nil; server = server.parent {
+		if server.methodNotAllowed != nil {
+			return server.methodNotAllowed
+		}
+	}
+	return self.status(http.StatusMethodNotAllowed)
+}
+
+// status returns the handler responding the given status code via the Error hook.
+func (self *server) status(code int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		self.error(w, r, code, nil)
+	})
+}
+
+// error responds the failed request via the Error hook of the server or its closest ancestor.
+func (self *server) error(w http.ResponseWriter, r *http.Request, status int, err error) {
+	for server := self; server != nil; server = server.parent {
+		if server.onError != nil {
+			server.onError(w, r, status, err)
+			return
+		}
+	}
+	RenderError(w, r, status, err)
+}
+
+// fail responds the error returned by the handler via the Error hook,
+// 500 Internal Server Error unless it is (or wraps) an HTTPError or StatusError.
+func (self *server) fail(w http.ResponseWriter, r *http.Request, err error) {
+	var status = http.StatusInternalServerError
+	var e *HTTPError
+	var s StatusError
+	if errors.As(err, &e) {
+		status = e.Status
+	} else if errors.As(err, &s) {
+		status = s.StatusCode()
+	}
+	self.error(w, r, status, err)
+}
+
+var errorPage = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html>
+<head><title>{{.Status}} {{.Message}}</title></head>
+<body><h1>{{.Status}} {{.Message}}</h1></body>
+</html>
+`))
+
+// RenderError is the default Error hook, which responds the public message (& details of
+// StatusError) in JSON or HTML (based on the Accept header), and logs the internal cause (if any).
+func RenderError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	var data = struct {
+		Status  int         `json:"status"`
+		Message string      `json:"message"`
+		Errors  interface{} `json:"errors,omitempty"`
+	}{Status: status, Message: http.StatusText(status)}
+
+	var cause = err
+	var e *HTTPError
+	var s StatusError
+	if errors.As(err, &e) {
+		data.Message, cause = e.message(), e.Err
+	} else if errors.As(err, &s) {
+		// public by design, nothing to log.
+		data.Errors, cause = s.Details(), nil
+	}
+	if cause != nil {
+		log.Errorf("%s %s => %d: %v", r.Method, r.URL.Path, status, cause)
+	}
+
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if negotiate(r, "text/html", "application/json") == "application/json" {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(data)
+	} else {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(status)
+		errorPage.Execute(w, data)
+	}
+}
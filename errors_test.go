@@ -0,0 +1,174 @@
+package rex
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestErrorHandlers(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Method)
+	}
+	app := New()
+	app.Get("/", handler)
+	app.Error(func(w http.ResponseWriter, r *http.Request, status int, err error) {
+		w.WriteHeader(status)
+		fmt.Fprintf(w, "<h1>%d</h1>", status)
+	})
+
+	api := app.Group("/api")
+	api.Get("/users", handler)
+	api.Error(func(w http.ResponseWriter, r *http.Request, status int, err error) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, `{"status":%d}`, status)
+	})
+
+	admin := app.Group("/admin")
+	admin.Get("/", handler)
+	admin.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "admin: not found", http.StatusNotFound)
+	})
+	admin.MethodNotAllowed(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "admin: not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	serve := func(method, url string) *httptest.ResponseRecorder {
+		request, _ := http.NewRequest(method, url, nil)
+		response := httptest.NewRecorder()
+		app.ServeHTTP(response, request)
+		return response
+	}
+
+	Convey("rex.Error", t, func() {
+		response := serve("GET", "/unknown")
+		So(response.Code, ShouldEqual, http.StatusNotFound)
+		So(response.Body.String(), ShouldEqual, "<h1>404</h1>")
+
+		response = serve("POST", "/")
+		So(response.Code, ShouldEqual, http.StatusMethodNotAllowed)
+		So(response.Body.String(), ShouldEqual, "<h1>405</h1>")
+	})
+
+	Convey("rex.Error (overridden by group)", t, func() {
+		response := serve("GET", "/api/unknown")
+		So(response.Code, ShouldEqual, http.StatusNotFound)
+		So(response.Header().Get("Content-Type"), ShouldEqual, "application/json")
+		So(response.Body.String(), ShouldEqual, `{"status":404}`)
+
+		response = serve("DELETE", "/api/users")
+		So(response.Code, ShouldEqual, http.StatusMethodNotAllowed)
+		So(response.Header().Get("Allow"), ShouldEqual, "GET, OPTIONS")
+		So(response.Body.String(), ShouldEqual, `{"status":405}`)
+	})
+
+	Convey("rex.NotFound & rex.MethodNotAllowed", t, func() {
+		response := serve("GET", "/admin/unknown")
+		So(response.Code, ShouldEqual, http.StatusNotFound)
+		So(response.Body.String(), ShouldEqual, "admin: not found\n")
+
+		response = serve("POST", "/admin/")
+		So(response.Code, ShouldEqual, http.StatusMethodNotAllowed)
+		So(response.Body.String(), ShouldEqual, "admin: not allowed\n")
+	})
+
+	Convey("rex.Error (default)", t, func() {
+		app := New()
+		app.Get("/", handler)
+		app.Group("/v1").Get("/", handler)
+
+		request, _ := http.NewRequest("GET", "/v1/unknown", nil)
+		response := httptest.NewRecorder()
+		app.ServeHTTP(response, request)
+		So(response.Code, ShouldEqual, http.StatusNotFound)
+		So(response.Header().Get("Content-Type"), ShouldEqual, "text/html; charset=utf-8")
+		So(response.Body.String(), ShouldContainSubstring, "<h1>404 Not Found</h1>")
+
+		// unclean paths are still redirected.
+		request, _ = http.NewRequest("GET", "/v1/../", nil)
+		response = httptest.NewRecorder()
+		app.ServeHTTP(response, request)
+		So(response.Code, ShouldEqual, http.StatusMovedPermanently)
+	})
+
+	Convey("rex.NotFound (unsupported)", t, func() {
+		So(func() { New().NotFound("handler") }, ShouldPanic)
+	})
+}
+
+func TestHTTPError(t *testing.T) {
+	app := New()
+	app.Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) error {
+		if id := app.Vars(r)["id"]; id != "1" {
+			return NewError(http.StatusNotFound, "user <"+id+"> not found", errors.New("sql: no rows"))
+		}
+		io.WriteString(w, "user 1")
+		return nil
+	})
+	app.Get("/crash", func(w http.ResponseWriter, r *http.Request) error {
+		return errors.New("database is down")
+	})
+	app.Get("/wrapped", func(w http.ResponseWriter, r *http.Request) error {
+		return fmt.Errorf("loading user: %w", NewError(http.StatusNotFound, "user not found", errors.New("sql: no rows")))
+	})
+
+	serve := func(url, accept string) *httptest.ResponseRecorder {
+		request, _ := http.NewRequest("GET", url, nil)
+		request.Header.Set("Accept", accept)
+		response := httptest.NewRecorder()
+		app.ServeHTTP(response, request)
+		return response
+	}
+
+	Convey("rex.HTTPError", t, func() {
+		err := NewError(http.StatusBadRequest, "", errors.New("cause"))
+		So(err.Error(), ShouldEqual, "400 Bad Request: cause")
+		So(err.Cause().Error(), ShouldEqual, "cause")
+		So(errors.Unwrap(err), ShouldEqual, err.Err)
+		So(NewError(http.StatusForbidden, "no way", nil).Error(), ShouldEqual, "403 no way")
+	})
+
+	Convey("rex.RenderError", t, func() {
+		response := serve("/users/1", "")
+		So(response.Code, ShouldEqual, http.StatusOK)
+		So(response.Body.String(), ShouldEqual, "user 1")
+
+		response = serve("/users/2", "application/json")
+		So(response.Code, ShouldEqual, http.StatusNotFound)
+		So(response.Header().Get("Content-Type"), ShouldEqual, "application/json; charset=utf-8")
+		So(response.Body.String(), ShouldEqual, `{"status":404,"message":"user \u003c2\u003e not found"}`+"\n")
+
+		response = serve("/users/2", "text/html,application/xhtml+xml,*/*;q=0.8")
+		So(response.Code, ShouldEqual, http.StatusNotFound)
+		So(response.Header().Get("Content-Type"), ShouldEqual, "text/html; charset=utf-8")
+		So(response.Body.String(), ShouldContainSubstring, "<h1>404 user &lt;2&gt; not found</h1>")
+
+		// internal cause never leaks.
+		response = serve("/crash", "application/json")
+		So(response.Code, ShouldEqual, http.StatusInternalServerError)
+		So(response.Body.String(), ShouldNotContainSubstring, "database")
+		So(response.Body.String(), ShouldContainSubstring, "Internal Server Error")
+
+		// wrapped HTTPError keeps its status & public message.
+		response = serve("/wrapped", "application/json")
+		So(response.Code, ShouldEqual, http.StatusNotFound)
+		So(response.Body.String(), ShouldEqual, `{"status":404,"message":"user not found"}`+"\n")
+	})
+
+	Convey("rex.Error (returned error)", t, func() {
+		var cause error
+		app.Error(func(w http.ResponseWriter, r *http.Request, status int, err error) {
+			cause = err
+			w.WriteHeader(status)
+		})
+		response := serve("/crash", "")
+		So(response.Code, ShouldEqual, http.StatusInternalServerError)
+		So(cause.Error(), ShouldEqual, "database is down")
+	})
+}
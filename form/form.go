@@ -1,24 +1,285 @@
 package form
 
 import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"reflect"
+	"sort"
+	"strings"
 
+	"github.com/goanywhere/rex"
+	"github.com/goanywhere/rex/middleware"
 	. "github.com/gorilla/schema"
 )
 
-var schema = NewDecoder()
+// FormKey is the key of the errors not bound to any field, e.g. returned by Validator.
+const FormKey = "_form"
+
+var schema, strict = NewDecoder(), NewDecoder()
+
+func init() {
+	// e.g. the "xsrftoken" field of middleware.XSRF.
+	schema.IgnoreUnknownKeys(true)
+	strict.IgnoreUnknownKeys(false)
+}
 
 type Validator interface {
 	Validate() error
 }
 
-// Parse parsed the raw query from the URL and updates request.Form,
-// decode the from to the given struct with Validator implemented.
-func parse(r *http.Request, form Validator) (err error) {
-	if err = r.ParseForm(); err == nil {
-		if err = schema.Decode(form, r.Form); err == nil {
-			err = form.Validate()
+// Errors maps the fields (named after their "schema"/"json" tags) to the validation messages.
+type Errors map[string][]string
+
+// Add appends the message to the field.
+func (self Errors) Add(field, message string) {
+	self[field] = append(self[field], message)
+}
+
+// StatusCode returns 422 Unprocessable Entity, responded by rex for the handlers returning Errors.
+func (self Errors) StatusCode() int {
+	return http.StatusUnprocessableEntity
+}
+
+// Details returns the messages of the fields, responded as "errors" by rex.RenderError.
+func (self Errors) Details() interface{} {
+	return map[string][]string(self)
+}
+
+func (self Errors) Error() string {
+	var fields []string
+	for field := range self {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	var messages []string
+	for _, field := range fields {
+		messages = append(messages, field+": "+strings.Join(self[field], ", "))
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Parser parses the request forms within the limits.
+type Parser struct {
+	// MaxMemory is the maximum bytes of the multipart form stored in memory, the rest in temp files.
+	MaxMemory int64
+	// MaxUploadSize is the maximum total bytes of the uploaded files, 0 for unlimited.
+	MaxUploadSize int64
+	// MaxBodySize is the maximum bytes of the request body, 0 for unlimited.
+	MaxBodySize int64
+	// DisallowUnknownFields rejects the JSON & form fields not present in the struct, XML
+	// bodies are rejected as 415 Unsupported Media Type since encoding/xml always ignores them.
+	DisallowUnknownFields bool
+}
+
+// DefaultParser is the Parser used by Parse.
+var DefaultParser = &Parser{MaxMemory: 32 << 20, MaxBodySize: 32 << 20}
+
+// Parse decodes the request body into the given struct pointer with the decoder chosen
+// by its Content-Type:
+//
+//	application/json, */*+json           encoding/json
+//	application/xml, text/xml, */*+xml   encoding/xml
+//	application/x-www-form-urlencoded    gorilla/schema, along with the raw query
+//	multipart/form-data                  gorilla/schema, along with the raw query & files
+//
+// then validates it with the "validate" tags (see Validate), followed by the Validator
+// interface (if implemented). Validation failures (including the values failed to convert)
+// are returned as Errors, while malformed bodies are returned as rex.HTTPError of
+// 400 Bad Request, 413 Request Entity Too Large or 415 Unsupported Media Type.
+//
+// Uploaded files are bound to the *multipart.FileHeader & []*multipart.FileHeader fields,
+// their temp files are removed once the request was served by rex, otherwise it is up to
+// the caller, i.e. `defer r.MultipartForm.RemoveAll()`.
+func Parse(r *http.Request, v interface{}) error {
+	return DefaultParser.Parse(r, v)
+}
+
+// Parse decodes & validates the request body into the given struct pointer, see Parse.
+func (self *Parser) Parse(r *http.Request, v interface{}) error {
+	if self.MaxBodySize <= 0 || r.Body == nil {
+		return self.parse(r, v)
+	}
+	r.Body = http.MaxBytesReader(nil, r.Body, self.MaxBodySize)
+	err := self.parse(r, v)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return rex.NewError(http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("request body must be at most %d bytes", self.MaxBodySize), err)
+	}
+	return err
+}
+
+func (self *Parser) parse(r *http.Request, v interface{}) error {
+	var values map[string][]string
+	var errors = make(Errors)
+	mediatype, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch {
+	case mediatype == "application/json" || strings.HasSuffix(mediatype, "+json"):
+		if err := self.decodeJSON(r.Body, v); err != nil {
+			if errs, ok := err.(Errors); ok {
+				errors = errs
+			} else {
+				return err
+			}
+		}
+
+	case mediatype == "application/xml" || mediatype == "text/xml" || strings.HasSuffix(mediatype, "+xml"):
+		if self.DisallowUnknownFields {
+			return rex.NewError(http.StatusUnsupportedMediaType, "unknown fields cannot be disallowed for XML", nil)
+		}
+		if r.Body == nil {
+			return malformed(io.EOF)
+		}
+		if err := xml.NewDecoder(r.Body).Decode(v); err != nil {
+			return malformed(err)
+		}
+
+	case mediatype == "multipart/form-data":
+		if err := r.ParseMultipartForm(self.MaxMemory); err != nil {
+			return malformed(err)
+		}
+		cleanup(r)
+		values = r.Form
+		if files := r.MultipartForm.File; len(files) > 0 {
+			if self.MaxUploadSize > 0 && total(files) > self.MaxUploadSize {
+				errors.Add(FormKey, fmt.Sprintf("uploaded files must be at most %d bytes in total", self.MaxUploadSize))
+			}
+			if value := reflect.Indirect(reflect.ValueOf(v)); value.Kind() == reflect.Struct {
+				bind(value, "", files)
+			}
+		}
+
+	case mediatype == "application/x-www-form-urlencoded" || mediatype == "":
+		if err := r.ParseForm(); err != nil {
+			return malformed(err)
 		}
+		values = r.Form
+
+	default:
+		return rex.NewError(http.StatusUnsupportedMediaType, fmt.Sprintf("unsupported media type %q", mediatype), nil)
+	}
+
+	if values != nil {
+		if err := self.decode(r, v, values); err != nil {
+			if errs, ok := err.(Errors); ok {
+				for field, messages := range errs {
+					errors[field] = append(errors[field], messages...)
+				}
+			} else {
+				return err
+			}
+		}
+	}
+	if err := Validate(v); err != nil {
+		invalid, ok := err.(Errors)
+		if !ok {
+			return err
+		}
+		for field, messages := range invalid {
+			// fields failed to convert are invalid already.
+			if _, exists := errors[field]; !exists {
+				errors[field] = messages
+			}
+		}
+	}
+	if len(errors) > 0 {
+		return errors
+	}
+	return nil
+}
+
+// decodeJSON binds the JSON body into v, values of mismatched types are returned as Errors.
+func (self *Parser) decodeJSON(body io.Reader, v interface{}) error {
+	if body == nil {
+		return malformed(io.EOF)
+	}
+	decoder := json.NewDecoder(body)
+	if self.DisallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+	err := decoder.Decode(v)
+	// the decoder carries on with the rest of the fields on type mismatches.
+	if e, ok := err.(*json.UnmarshalTypeError); ok && e.Field != "" {
+		return Errors{e.Field: {"is invalid"}}
+	}
+	if err != nil {
+		return malformed(err)
+	}
+	return nil
+}
+
+// decode binds the form values into the struct, values failed to convert are returned as Errors.
+func (self *Parser) decode(r *http.Request, v interface{}, values map[string][]string) error {
+	var decoder = schema
+	if self.DisallowUnknownFields {
+		decoder = strict
+		if field, _ := middleware.XSRFField(r); values[field] != nil {
+			values = copyValues(values)
+			delete(values, field)
+		}
+	}
+	err := decoder.Decode(v, values)
+	if multi, ok := err.(MultiError); ok {
+		var errors = make(Errors)
+		for key, err := range multi {
+			if e, ok := err.(ConversionError); ok {
+				key = e.Key
+			} else if strings.HasPrefix(err.Error(), "schema: invalid path") {
+				return rex.NewError(http.StatusBadRequest, fmt.Sprintf("unknown field %q", key), nil)
+			}
+			errors.Add(key, "is invalid")
+		}
+		return errors
+	}
+	return err
+}
+
+func copyValues(values map[string][]string) map[string][]string {
+	var copied = make(map[string][]string, len(values))
+	for key, value := range values {
+		copied[key] = value
+	}
+	return copied
+}
+
+// malformed wraps the decoding error as 400 Bad Request.
+func malformed(err error) error {
+	return rex.NewError(http.StatusBadRequest, "malformed request body", err)
+}
+
+// Validate checks the struct against the rules of its "validate" tags, e.g.
+//
+//	Username string `schema:"username" validate:"required,min=3,max=20,regexp=^[a-z0-9]+$"`
+//
+// followed by the Validator interface (if implemented), errors are returned as Errors.
+// See rules for the supported rules, while malformed tags are returned as plain errors.
+func Validate(v interface{}) error {
+	var errors = make(Errors)
+	value := reflect.Indirect(reflect.ValueOf(v))
+	if value.Kind() == reflect.Struct {
+		if err := validate(value, "", errors); err != nil {
+			return err
+		}
+	}
+	if validator, ok := v.(Validator); ok {
+		if err := validator.Validate(); err != nil {
+			if errs, ok := err.(Errors); ok {
+				for field, messages := range errs {
+					errors[field] = append(errors[field], messages...)
+				}
+			} else {
+				errors.Add(FormKey, err.Error())
+			}
+		}
+	}
+	if len(errors) > 0 {
+		return errors
 	}
-	return
+	return nil
 }
@@ -4,11 +4,15 @@ import (
 	"bytes"
 	"errors"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 
+	"github.com/goanywhere/rex"
+	"github.com/goanywhere/rex/middleware"
 	. "github.com/smartystreets/goconvey/convey"
 )
 
@@ -31,7 +35,7 @@ func (self *user) Validate() error {
 func TestParse(t *testing.T) {
 	app := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var form user
-		if err := parse(r, &form); err == nil {
+		if err := Parse(r, &form); err == nil {
 			w.WriteHeader(http.StatusAccepted)
 			io.WriteString(w, "uid")
 		} else {
@@ -60,3 +64,178 @@ func TestParse(t *testing.T) {
 		So(response.Code, ShouldEqual, http.StatusBadRequest)
 	})
 }
+
+type profile struct {
+	Name string `schema:"name" json:"name" xml:"name" validate:"required"`
+	Age  int    `schema:"age" json:"age" xml:"age" validate:"min=18"`
+}
+
+func TestParseBodies(t *testing.T) {
+	Convey("rex.form.Parse (multipart)", t, func() {
+		var body = new(bytes.Buffer)
+		writer := multipart.NewWriter(body)
+		writer.WriteField("name", "rex")
+		writer.WriteField("age", "20")
+		writer.WriteField("xsrftoken", "token")
+		writer.Close()
+
+		request, _ := http.NewRequest("POST", "/", body)
+		request.Header.Set("Content-Type", writer.FormDataContentType())
+		var form profile
+		So(Parse(request, &form), ShouldBeNil)
+		So(form, ShouldResemble, profile{"rex", 20})
+	})
+
+	Convey("rex.form.Parse (JSON)", t, func() {
+		request, _ := http.NewRequest("POST", "/", bytes.NewBufferString(`{"name": "rex", "age": 7}`))
+		request.Header.Set("Content-Type", "application/json; charset=utf-8")
+		var form profile
+		So(Parse(request, &form).(Errors), ShouldResemble, Errors{"age": {"must be at least 18"}})
+		So(form.Name, ShouldEqual, "rex")
+	})
+
+	Convey("rex.form.Parse (invalid values)", t, func() {
+		request, _ := http.NewRequest("GET", "/?age=abc", nil)
+		var form profile
+		So(Parse(request, &form).(Errors), ShouldResemble, Errors{"age": {"is invalid"}, "name": {"is required"}})
+	})
+}
+
+func status(err error) int {
+	if e, ok := err.(*rex.HTTPError); ok {
+		return e.Status
+	}
+	return 0
+}
+
+func TestParseContentTypes(t *testing.T) {
+	post := func(contentType, body string) *http.Request {
+		request, _ := http.NewRequest("POST", "/", strings.NewReader(body))
+		request.Header.Set("Content-Type", contentType)
+		return request
+	}
+
+	Convey("rex.form.Parse (XML)", t, func() {
+		var form profile
+		So(Parse(post("application/xml", `<profile><name>rex</name><age>20</age></profile>`), &form), ShouldBeNil)
+		So(form, ShouldResemble, profile{"rex", 20})
+
+		form = profile{}
+		So(Parse(post("text/xml", `<profile><age>20</age></profile>`), &form).(Errors), ShouldResemble, Errors{"name": {"is required"}})
+	})
+
+	Convey("rex.form.Parse (JSON types)", t, func() {
+		var form profile
+		So(Parse(post("application/vnd.api+json", `{"name": "rex", "age": "20"}`), &form).(Errors), ShouldResemble, Errors{"age": {"is invalid"}})
+		So(form.Name, ShouldEqual, "rex")
+	})
+
+	Convey("rex.form.Parse (malformed)", t, func() {
+		var form profile
+		So(status(Parse(post("application/json", `{"name": `), &form)), ShouldEqual, http.StatusBadRequest)
+		So(status(Parse(post("application/json", ``), &form)), ShouldEqual, http.StatusBadRequest)
+		So(status(Parse(post("application/xml", `<profile>`), &form)), ShouldEqual, http.StatusBadRequest)
+		So(status(Parse(post("text/plain", `rex`), &form)), ShouldEqual, http.StatusUnsupportedMediaType)
+	})
+
+	Convey("rex.form.Parser (MaxBodySize)", t, func() {
+		parser := &Parser{MaxBodySize: 16}
+		var form profile
+		So(parser.Parse(post("application/json", `{"name": "rex", "age": 20}`), &form), ShouldHaveSameTypeAs, &rex.HTTPError{})
+		So(status(parser.Parse(post("application/json", `{"name": "rex", "age": 20}`), &form)), ShouldEqual, http.StatusRequestEntityTooLarge)
+		So(status(parser.Parse(post("application/x-www-form-urlencoded", "name=rex&age=20&bio=rex"), &form)), ShouldEqual, http.StatusRequestEntityTooLarge)
+		So(status(parser.Parse(post("application/xml", `<profile><name>rex</name></profile>`), &form)), ShouldEqual, http.StatusRequestEntityTooLarge)
+
+		var body = new(bytes.Buffer)
+		writer := multipart.NewWriter(body)
+		writer.WriteField("name", "rex")
+		writer.Close()
+		So(status(parser.Parse(post(writer.FormDataContentType(), body.String()), &form)), ShouldEqual, http.StatusRequestEntityTooLarge)
+
+		form = profile{}
+		So(parser.Parse(post("application/json", `{"age": 20}`), &form).(Errors), ShouldResemble, Errors{"name": {"is required"}})
+	})
+
+	Convey("rex.form.Parser (DisallowUnknownFields)", t, func() {
+		parser := &Parser{DisallowUnknownFields: true}
+		var form profile
+		So(status(parser.Parse(post("application/json", `{"name": "rex", "age": 20, "admin": true}`), &form)), ShouldEqual, http.StatusBadRequest)
+		So(status(parser.Parse(post("application/x-www-form-urlencoded", "name=rex&age=20&admin=1"), &form)), ShouldEqual, http.StatusBadRequest)
+
+		form = profile{}
+		So(parser.Parse(post("application/x-www-form-urlencoded", "name=rex&age=20&xsrftoken=token"), &form), ShouldBeNil)
+		So(form, ShouldResemble, profile{"rex", 20})
+		So(Parse(post("application/json", `{"name": "rex", "age": 20, "admin": true}`), &form), ShouldBeNil)
+
+		// unknown XML elements cannot be detected by encoding/xml.
+		So(status(parser.Parse(post("application/xml", `<profile><name>rex</name><age>20</age></profile>`), &form)), ShouldEqual, http.StatusUnsupportedMediaType)
+		form = profile{}
+		So(Parse(post("application/xml", `<profile><name>rex</name><age>20</age><admin>true</admin></profile>`), &form), ShouldBeNil)
+		So(form, ShouldResemble, profile{"rex", 20})
+	})
+}
+
+func TestParseErrors(t *testing.T) {
+	app := rex.New()
+	app.Post("/profiles", func(w http.ResponseWriter, r *http.Request) error {
+		var form profile
+		if err := Parse(r, &form); err != nil {
+			return err
+		}
+		io.WriteString(w, form.Name)
+		return nil
+	})
+
+	Convey("rex.form.Errors (returned by the handler)", t, func() {
+		request := httptest.NewRequest("POST", "/profiles", strings.NewReader(`{"age": 7}`))
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("Accept", "application/json")
+		response := httptest.NewRecorder()
+		app.ServeHTTP(response, request)
+
+		So(response.Code, ShouldEqual, http.StatusUnprocessableEntity)
+		So(response.Body.String(), ShouldEqual, `{"status":422,"message":"Unprocessable Entity","errors":{"age":["must be at least 18"],"name":["is required"]}}`+"\n")
+	})
+}
+
+func TestParseXSRF(t *testing.T) {
+	app := rex.New()
+	app.Use(middleware.XSRF)
+	app.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		_, token := middleware.XSRFField(r)
+		io.WriteString(w, token)
+	})
+	app.Post("/", func(w http.ResponseWriter, r *http.Request) error {
+		var form profile
+		if err := (&Parser{MaxBodySize: 1 << 10}).Parse(r, &form); err != nil {
+			return err
+		}
+		io.WriteString(w, form.Name)
+		return nil
+	})
+
+	response := httptest.NewRecorder()
+	app.ServeHTTP(response, httptest.NewRequest("GET", "http://example.com/", nil))
+	cookie := (&http.Response{Header: response.Header()}).Cookies()[0]
+	token := response.Body.String()
+
+	post := func(values url.Values) *httptest.ResponseRecorder {
+		values.Set(middleware.XSRFFieldName, token)
+		request := httptest.NewRequest("POST", "http://example.com/", strings.NewReader(values.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.Header.Set("Origin", "http://example.com")
+		request.AddCookie(cookie)
+		response := httptest.NewRecorder()
+		app.ServeHTTP(response, request)
+		return response
+	}
+
+	Convey("rex.form.Parser (MaxBodySize behind middleware.XSRF)", t, func() {
+		response := post(url.Values{"name": {"rex"}, "age": {"20"}})
+		So(response.Code, ShouldEqual, http.StatusOK)
+		So(response.Body.String(), ShouldEqual, "rex")
+
+		response = post(url.Values{"name": {strings.Repeat("rex", 10<<10)}, "age": {"20"}})
+		So(response.Code, ShouldEqual, http.StatusRequestEntityTooLarge)
+	})
+}
@@ -0,0 +1,169 @@
+package form
+
+import (
+	"encoding"
+	"fmt"
+	"html/template"
+	"net/http"
+	"reflect"
+
+	"github.com/goanywhere/rex"
+	"github.com/goanywhere/rex/middleware"
+)
+
+// Form is the view model to re-render the submitted form along with its errors, e.g.
+//
+//	var data signup
+//	if err := form.Parse(r, &data); err != nil {
+//		return rex.HTML(w, r, http.StatusUnprocessableEntity, "signup.html", form.New(r, &data, err))
+//	}
+//
+// and within the template:
+//
+//	<form method="post">
+//		{{.XSRFField}}
+//		{{range .Errors}}<p class="error">{{.}}</p>{{end}}
+//		{{with .Field "email"}}
+//		<input name="{{.Name}}" value="{{.Value}}">
+//		{{range .Errors}}<span class="error">{{.}}</span>{{end}}
+//		{{end}}
+//	</form>
+type Form struct {
+	input  template.HTML
+	token  string
+	raw    map[string][]string
+	values map[string][]string
+	errors Errors
+}
+
+// Field is the submitted value(s) & errors of the form field.
+type Field struct {
+	Name   string
+	Values []string
+	Errors []string
+}
+
+// Value returns the first submitted value of the field.
+func (self *Field) Value() string {
+	if len(self.Values) > 0 {
+		return self.Values[0]
+	}
+	return ""
+}
+
+// Has reports whether the value was submitted, e.g. for checkboxes & options.
+func (self *Field) Has(value string) bool {
+	for _, v := range self.Values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Invalid reports whether the field has any errors.
+func (self *Field) Invalid() bool {
+	return len(self.Errors) > 0
+}
+
+// New creates the form view of the request with the struct v (if any) & the error returned
+// by Parse (if any). The raw submitted values take precedence over the struct ones, thus the
+// values failed to convert are re-rendered as is; errors other than Errors are form errors.
+func New(r *http.Request, v interface{}, err error) *Form {
+	var form = &Form{
+		raw:    r.Form,
+		values: make(map[string][]string),
+		errors: make(Errors),
+	}
+	_, form.token = middleware.XSRFField(r)
+	form.input = middleware.XSRFInput(r)
+	if value := reflect.Indirect(reflect.ValueOf(v)); value.Kind() == reflect.Struct {
+		collect(value, "", form.values)
+	}
+	switch e := err.(type) {
+	case nil:
+	case Errors:
+		form.errors = e
+	case *rex.HTTPError:
+		message := e.Message
+		if message == "" {
+			message = http.StatusText(e.Status)
+		}
+		form.errors.Add(FormKey, message)
+	default:
+		form.errors.Add(FormKey, e.Error())
+	}
+	return form
+}
+
+// Field returns the named field, nested ones in dotted notation, e.g. "address.city".
+func (self *Form) Field(name string) *Field {
+	values, ok := self.raw[name]
+	if !ok {
+		values = self.values[name]
+	}
+	return &Field{Name: name, Values: values, Errors: self.errors[name]}
+}
+
+// Errors returns the errors not bound to any field, i.e. those under FormKey.
+func (self *Form) Errors() []string {
+	return self.errors[FormKey]
+}
+
+// Invalid reports whether the form has any errors.
+func (self *Form) Invalid() bool {
+	return len(self.errors) > 0
+}
+
+// XSRFToken returns the XSRF token published by middleware.XSRF.
+func (self *Form) XSRFToken() string {
+	return self.token
+}
+
+// XSRFField returns the hidden input carrying the XSRF token expected by middleware.XSRF.
+func (self *Form) XSRFField() template.HTML {
+	return self.input
+}
+
+// collect formats the field values of the struct (nested ones included) by their names.
+func collect(value reflect.Value, prefix string, values map[string][]string) {
+	for index := 0; index < value.NumField(); index++ {
+		field := value.Type().Field(index)
+		if field.PkgPath != "" || field.Type == fileType || field.Type == filesType {
+			continue
+		}
+		name := prefix + fieldName(field)
+		target := value.Field(index)
+
+		if text, ok := format(target); ok {
+			values[name] = []string{text}
+			continue
+		}
+		switch target = reflect.Indirect(target); target.Kind() {
+		case reflect.Struct:
+			collect(target, name+".", values)
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < target.Len(); i++ {
+				if text, ok := format(target.Index(i)); ok {
+					values[name] = append(values[name], text)
+				}
+			}
+		}
+	}
+}
+
+// format returns the text of the value implementing encoding.TextMarshaler, or of the plain value.
+func format(value reflect.Value) (string, bool) {
+	if value.Kind() == reflect.Ptr && value.IsNil() {
+		return "", false
+	}
+	if marshaler, ok := value.Interface().(encoding.TextMarshaler); ok {
+		text, err := marshaler.MarshalText()
+		return string(text), err == nil
+	}
+	switch reflect.Indirect(value).Kind() {
+	case reflect.Struct, reflect.Slice, reflect.Array, reflect.Map, reflect.Interface, reflect.Func, reflect.Chan:
+		return "", false
+	}
+	return fmt.Sprint(reflect.Indirect(value).Interface()), true
+}
@@ -0,0 +1,85 @@
+package form
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goanywhere/rex"
+	"github.com/goanywhere/rex/internal"
+	"github.com/goanywhere/rex/middleware"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type location struct {
+	City string `schema:"city"`
+}
+
+type registration struct {
+	Email    string    `schema:"email" validate:"required,email"`
+	Age      int       `schema:"age"`
+	Tags     []string  `schema:"tags"`
+	Birthday time.Time `schema:"-"`
+	Address  location  `schema:"address"`
+}
+
+func TestForm(t *testing.T) {
+	Convey("rex.form.New", t, func() {
+		request, _ := http.NewRequest("POST", "/", strings.NewReader("email=rex&age=abc&tags=a&tags=b&address.city=Oslo"))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		var data = registration{Birthday: time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC)}
+		form := New(request, &data, Parse(request, &data))
+
+		So(form.Invalid(), ShouldBeTrue)
+		So(form.Errors(), ShouldBeEmpty)
+
+		email := form.Field("email")
+		So(email.Name, ShouldEqual, "email")
+		So(email.Value(), ShouldEqual, "rex")
+		So(email.Invalid(), ShouldBeTrue)
+		So(email.Errors, ShouldResemble, []string{"must be a valid email address"})
+
+		// the raw value failed to convert.
+		So(form.Field("age").Value(), ShouldEqual, "abc")
+		So(form.Field("age").Errors, ShouldResemble, []string{"is invalid"})
+
+		So(form.Field("tags").Has("b"), ShouldBeTrue)
+		So(form.Field("tags").Has("c"), ShouldBeFalse)
+		So(form.Field("address.city").Value(), ShouldEqual, "Oslo")
+		So(form.Field("Birthday").Value(), ShouldEqual, "2000-01-02T00:00:00Z")
+		So(form.Field("missing").Value(), ShouldBeEmpty)
+		So(form.Field("missing").Invalid(), ShouldBeFalse)
+	})
+
+	Convey("rex.form.New (JSON)", t, func() {
+		request, _ := http.NewRequest("POST", "/", strings.NewReader(`{"Email": "rex@example.com", "Tags": ["a"], "Address": {"City": "Oslo"}}`))
+		request.Header.Set("Content-Type", "application/json")
+		var data registration
+		form := New(request, &data, Parse(request, &data))
+		So(form.Invalid(), ShouldBeFalse)
+		So(form.Field("email").Value(), ShouldEqual, "rex@example.com")
+		So(form.Field("tags").Values, ShouldResemble, []string{"a"})
+		So(form.Field("address.city").Value(), ShouldEqual, "Oslo")
+
+		form = New(request, &data, rex.NewError(http.StatusBadRequest, "", nil))
+		So(form.Errors(), ShouldResemble, []string{"Bad Request"})
+	})
+
+	Convey("rex.form.Form (XSRF)", t, func() {
+		request, _ := http.NewRequest("GET", "/", nil)
+		request = internal.WithContext(request, new(internal.Context))
+		rex.Set(request, middleware.XSRFTokenKey, `to"ken`)
+
+		form := New(request, nil, nil)
+		So(form.XSRFToken(), ShouldEqual, `to"ken`)
+		So(string(form.XSRFField()), ShouldEqual, `<input type="hidden" name="xsrftoken" value="to&#34;ken">`)
+
+		var buffer bytes.Buffer
+		tmpl := template.Must(template.New("form").Parse(`{{.XSRFField}}{{with .Field "email"}}<input name="{{.Name}}" value="{{.Value}}">{{end}}`))
+		So(tmpl.Execute(&buffer, form), ShouldBeNil)
+		So(buffer.String(), ShouldEqual, `<input type="hidden" name="xsrftoken" value="to&#34;ken"><input name="email" value="">`)
+	})
+}
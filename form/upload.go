@@ -0,0 +1,217 @@
+package form
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/goanywhere/rex/internal"
+)
+
+var (
+	fileType  = reflect.TypeOf((*multipart.FileHeader)(nil))
+	filesType = reflect.TypeOf([]*multipart.FileHeader(nil))
+
+	extPattern = regexp.MustCompile(`^\.[a-z0-9]{1,16}$`)
+)
+
+func init() {
+	// rules for the uploaded files (*multipart.FileHeader or []*multipart.FileHeader), e.g.
+	//
+	//	Avatar *multipart.FileHeader `schema:"avatar" validate:"required,maxsize=2MB,types=image/png image/jpeg"`
+	//
+	//	maxsize=N      maximum size of each file, in bytes or with KB/MB/GB suffix
+	//	types=a/b c/*  allowed content types of each file, sniffed via http.DetectContentType
+	rules["maxsize"] = rule{
+		check: func(value reflect.Value, arg string) string {
+			limit, _ := bytesize(arg)
+			for _, header := range uploads(value) {
+				if header.Size > limit {
+					return "must be at most " + arg
+				}
+			}
+			return ""
+		},
+		accepts: func(kind reflect.Type, arg string) error {
+			if _, err := bytesize(arg); err != nil {
+				return err
+			}
+			return uploadable(kind)
+		},
+	}
+	rules["types"] = rule{
+		check: func(value reflect.Value, arg string) string {
+			allowed := strings.Fields(arg)
+			for _, header := range uploads(value) {
+				if !accepted(sniff(header), allowed) {
+					return "must be one of the types: " + strings.Join(allowed, ", ")
+				}
+			}
+			return ""
+		},
+		accepts: func(kind reflect.Type, arg string) error {
+			if len(strings.Fields(arg)) == 0 {
+				return fmt.Errorf("missing types")
+			}
+			return uploadable(kind)
+		},
+	}
+}
+
+// uploadable verifies the field type of the file rules.
+func uploadable(kind reflect.Type) error {
+	if kind != fileType && kind != filesType {
+		return fmt.Errorf("unsupported type %v", kind)
+	}
+	return nil
+}
+
+// bind sets the uploaded files to the *multipart.FileHeader & []*multipart.FileHeader fields
+// (nested ones included) of the struct.
+func bind(value reflect.Value, prefix string, files map[string][]*multipart.FileHeader) {
+	for index := 0; index < value.NumField(); index++ {
+		field := value.Type().Field(index)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := prefix + fieldName(field)
+		target := value.Field(index)
+
+		switch field.Type {
+		case fileType:
+			if headers := files[name]; len(headers) > 0 {
+				target.Set(reflect.ValueOf(headers[0]))
+			}
+		case filesType:
+			if headers := files[name]; len(headers) > 0 {
+				target.Set(reflect.ValueOf(headers))
+			}
+		default:
+			if target.Kind() == reflect.Struct {
+				bind(target, name+".", files)
+			}
+		}
+	}
+}
+
+// uploads returns the uploaded files of the (indirect) field value.
+func uploads(value reflect.Value) []*multipart.FileHeader {
+	switch {
+	case value.Type() == filesType:
+		return value.Interface().([]*multipart.FileHeader)
+	case value.CanAddr() && value.Addr().Type() == fileType:
+		return []*multipart.FileHeader{value.Addr().Interface().(*multipart.FileHeader)}
+	}
+	return nil
+}
+
+// cleanup removes the temp files of the multipart form once the request was served by rex.
+func cleanup(r *http.Request) {
+	if ctx := internal.FromRequest(r); ctx != nil && r.MultipartForm != nil {
+		form := r.MultipartForm
+		ctx.OnFinish(func() {
+			form.RemoveAll()
+		})
+	}
+}
+
+// total returns the total size of the uploaded files.
+func total(files map[string][]*multipart.FileHeader) (size int64) {
+	for _, headers := range files {
+		for _, header := range headers {
+			size += header.Size
+		}
+	}
+	return
+}
+
+// sniff detects the content type of the uploaded file (without parameters) from its content.
+func sniff(header *multipart.FileHeader) string {
+	file, err := header.Open()
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	var buffer = make([]byte, 512)
+	n, _ := io.ReadFull(file, buffer)
+	mediatype, _, _ := mime.ParseMediaType(http.DetectContentType(buffer[:n]))
+	return mediatype
+}
+
+// accepted reports whether the content type matches any of the allowed ones, e.g. "image/*".
+func accepted(mediatype string, allowed []string) bool {
+	for _, pattern := range allowed {
+		if pattern == mediatype || strings.HasSuffix(pattern, "/*") && strings.HasPrefix(mediatype, strings.TrimSuffix(pattern, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// bytesize parses the size in bytes, or with KB/MB/GB suffix.
+func bytesize(arg string) (int64, error) {
+	var units = []struct {
+		suffix string
+		scale  int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}}
+
+	var scale int64 = 1
+	for _, unit := range units {
+		if strings.HasSuffix(strings.ToUpper(arg), unit.suffix) {
+			arg, scale = strings.TrimSpace(arg[:len(arg)-len(unit.suffix)]), unit.scale
+			break
+		}
+	}
+	n, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", arg)
+	}
+	return n * scale, nil
+}
+
+// Save streams the uploaded file into the directory under a random name (with the sanitized
+// extension of the original one), the client filename is never used as the path, returns the
+// path of the saved file.
+func Save(header *multipart.FileHeader, dir string) (filename string, err error) {
+	var random = make([]byte, 16)
+	if _, err = rand.Read(random); err != nil {
+		return
+	}
+	name := hex.EncodeToString(random)
+	if ext := strings.ToLower(filepath.Ext(filepath.Base(header.Filename))); extPattern.MatchString(ext) {
+		name += ext
+	}
+	filename = filepath.Join(dir, name)
+
+	src, err := header.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", err
+	}
+	if _, err = io.Copy(dst, src); err == nil {
+		err = dst.Close()
+	} else {
+		dst.Close()
+	}
+	if err != nil {
+		os.Remove(filename)
+		return "", fmt.Errorf("form: failed to save %q: %v", header.Filename, err)
+	}
+	return filename, nil
+}
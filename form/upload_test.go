@@ -0,0 +1,151 @@
+package form
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/goanywhere/rex"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var png = []byte("\x89PNG\x0D\x0A\x1A\x0A" + strings.Repeat("\x00", 64))
+
+type upload struct {
+	Title       string                  `schema:"title" validate:"required"`
+	Avatar      *multipart.FileHeader   `schema:"avatar" validate:"required,maxsize=1KB,types=image/*"`
+	Attachments []*multipart.FileHeader `schema:"attachments" validate:"max=2,types=text/plain image/png"`
+}
+
+func multipartRequest(fields map[string]string, files map[string][][]byte) *http.Request {
+	var body = new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	for name, value := range fields {
+		writer.WriteField(name, value)
+	}
+	for name, contents := range files {
+		for _, content := range contents {
+			part, _ := writer.CreateFormFile(name, "../../etc/"+name+".PNG")
+			part.Write(content)
+		}
+	}
+	writer.Close()
+
+	request, _ := http.NewRequest("POST", "/", body)
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+	return request
+}
+
+func TestUpload(t *testing.T) {
+	Convey("rex.form.Parse (files)", t, func() {
+		request := multipartRequest(map[string]string{"title": "rex"}, map[string][][]byte{
+			"avatar":      {png},
+			"attachments": {[]byte("hello"), png},
+		})
+		var form upload
+		So(Parse(request, &form), ShouldBeNil)
+		So(form.Title, ShouldEqual, "rex")
+		So(form.Avatar, ShouldNotBeNil)
+		So(form.Attachments, ShouldHaveLength, 2)
+	})
+
+	Convey("rex.form.Parse (file rules)", t, func() {
+		request := multipartRequest(nil, map[string][][]byte{
+			"avatar":      {append(png, bytes.Repeat([]byte{0}, 1024)...)},
+			"attachments": {[]byte("a"), []byte("b"), []byte("%PDF-1.4")},
+		})
+		var form upload
+		So(Parse(request, &form).(Errors), ShouldResemble, Errors{
+			"title":       {"is required"},
+			"avatar":      {"must be at most 1KB"},
+			"attachments": {"must be at most 2 items", "must be one of the types: text/plain, image/png"},
+		})
+
+		request = multipartRequest(map[string]string{"title": "rex"}, map[string][][]byte{"avatar": {[]byte("text")}})
+		form = upload{}
+		So(Parse(request, &form).(Errors), ShouldResemble, Errors{"avatar": {"must be one of the types: image/*"}})
+
+		request = multipartRequest(map[string]string{"title": "rex"}, nil)
+		form = upload{}
+		So(Parse(request, &form).(Errors), ShouldResemble, Errors{"avatar": {"is required"}})
+	})
+
+	Convey("rex.form.Parser (MaxUploadSize)", t, func() {
+		request := multipartRequest(map[string]string{"title": "rex"}, map[string][][]byte{"avatar": {png}})
+		parser := &Parser{MaxMemory: 1 << 10, MaxUploadSize: 10}
+		var form upload
+		So(parser.Parse(request, &form).(Errors), ShouldResemble, Errors{FormKey: {"uploaded files must be at most 10 bytes in total"}})
+	})
+
+	Convey("rex.form.Parse (temp files)", t, func() {
+		dir, _ := ioutil.TempDir("", "rex")
+		defer os.RemoveAll(dir)
+		defer os.Setenv("TMPDIR", os.Getenv("TMPDIR"))
+		os.Setenv("TMPDIR", dir)
+
+		var spilled int
+		app := rex.New()
+		app.Post("/", func(w http.ResponseWriter, r *http.Request) error {
+			var form struct {
+				File *multipart.FileHeader `schema:"file"`
+			}
+			if err := (&Parser{MaxMemory: 1 << 10}).Parse(r, &form); err != nil {
+				return err
+			}
+			files, _ := ioutil.ReadDir(dir)
+			spilled = len(files)
+			return nil
+		})
+		request := multipartRequest(nil, map[string][][]byte{"file": {bytes.Repeat([]byte("rex"), 100<<10)}})
+		response := httptest.NewRecorder()
+		app.ServeHTTP(response, request)
+		So(response.Code, ShouldEqual, http.StatusOK)
+		So(spilled, ShouldEqual, 1)
+
+		files, _ := ioutil.ReadDir(dir)
+		So(files, ShouldBeEmpty)
+	})
+
+	Convey("rex.form.Save", t, func() {
+		dir, _ := ioutil.TempDir("", "rex")
+		defer os.RemoveAll(dir)
+
+		request := multipartRequest(map[string]string{"title": "rex"}, map[string][][]byte{"avatar": {png}})
+		var form upload
+		So(Parse(request, &form), ShouldBeNil)
+
+		filename, err := Save(form.Avatar, dir)
+		So(err, ShouldBeNil)
+		So(filepath.Dir(filename), ShouldEqual, dir)
+		So(filepath.Ext(filename), ShouldEqual, ".png")
+		content, _ := ioutil.ReadFile(filename)
+		So(content, ShouldResemble, png)
+
+		another, err := Save(form.Avatar, dir)
+		So(err, ShouldBeNil)
+		So(another, ShouldNotEqual, filename)
+
+		_, err = Save(form.Avatar, filepath.Join(dir, "missing"))
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("rex.form.bytesize", t, func() {
+		size, err := bytesize("512")
+		So(size, ShouldEqual, 512)
+		So(err, ShouldBeNil)
+		size, _ = bytesize("2KB")
+		So(size, ShouldEqual, 2048)
+		size, _ = bytesize("3mb")
+		So(size, ShouldEqual, 3<<20)
+		_, err = bytesize("lots")
+		So(err, ShouldNotBeNil)
+		_, err = bytesize("-1KB")
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,325 @@
+package form
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	emailPattern = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+	timeType     = reflect.TypeOf(time.Time{})
+
+	patterns = struct {
+		sync.RWMutex
+		cache map[string]*regexp.Regexp
+	}{cache: make(map[string]*regexp.Regexp)}
+
+	structs = struct {
+		sync.RWMutex
+		cache map[reflect.Type][]field
+	}{cache: make(map[reflect.Type][]field)}
+)
+
+// rule checks the field value against the argument, returns the message if failed.
+type rule struct {
+	check func(value reflect.Value, arg string) string
+	// accepts verifies the argument & the field type once the struct is compiled.
+	accepts func(kind reflect.Type, arg string) error
+}
+
+// field is the compiled "validate" tag of the struct field.
+type field struct {
+	index  int
+	name   string
+	rules  [][2]string // name & argument of the rules, in order.
+	nested bool        // validate the fields of the (pointer to) struct as well.
+}
+
+// rules supported by the "validate" tags, comma separated, e.g. `validate:"required,max=20"`:
+//
+//	required           value must not be empty (blank strings included)
+//	min=N / max=N      minimum/maximum number, or length of string/slice/map
+//	length=N           exact length of string/slice/map
+//	email              string must be an email address
+//	oneof=a b c        value must be one of the space separated values
+//	regexp=PATTERN     string must match the pattern, must be the last rule (may contain commas)
+//
+// Absent values (see isAbsent) are only checked by "required".
+var rules = map[string]rule{
+	"min": {
+		check: func(value reflect.Value, arg string) string {
+			if n, unit := measure(value); n < number(arg) {
+				return strings.TrimSpace(fmt.Sprintf("must be at least %s %s", arg, unit))
+			}
+			return ""
+		},
+		accepts: measurable,
+	},
+	"max": {
+		check: func(value reflect.Value, arg string) string {
+			if n, unit := measure(value); n > number(arg) {
+				return strings.TrimSpace(fmt.Sprintf("must be at most %s %s", arg, unit))
+			}
+			return ""
+		},
+		accepts: measurable,
+	},
+	"length": {
+		check: func(value reflect.Value, arg string) string {
+			if n, unit := measure(value); n != number(arg) {
+				return strings.TrimSpace(fmt.Sprintf("must be exactly %s %s", arg, unit))
+			}
+			return ""
+		},
+		accepts: measurable,
+	},
+	"email": {
+		check: func(value reflect.Value, arg string) string {
+			if !emailPattern.MatchString(fmt.Sprint(value.Interface())) {
+				return "must be a valid email address"
+			}
+			return ""
+		},
+		accepts: func(kind reflect.Type, arg string) error { return nil },
+	},
+	"oneof": {
+		check: func(value reflect.Value, arg string) string {
+			var text = fmt.Sprint(value.Interface())
+			for _, option := range strings.Fields(arg) {
+				if option == text {
+					return ""
+				}
+			}
+			return "must be one of: " + strings.Join(strings.Fields(arg), ", ")
+		},
+		accepts: func(kind reflect.Type, arg string) error {
+			if len(strings.Fields(arg)) == 0 {
+				return fmt.Errorf("missing options")
+			}
+			return nil
+		},
+	},
+	"regexp": {
+		check: func(value reflect.Value, arg string) string {
+			if compiled, _ := pattern(arg); compiled == nil || !compiled.MatchString(fmt.Sprint(value.Interface())) {
+				return "is invalid"
+			}
+			return ""
+		},
+		accepts: func(kind reflect.Type, arg string) error {
+			_, err := pattern(arg)
+			return err
+		},
+	},
+}
+
+// validate checks the fields of the struct (nested ones included) against their rules.
+func validate(value reflect.Value, prefix string, errors Errors) error {
+	fields, err := compile(value.Type())
+	if err != nil {
+		return err
+	}
+	for _, field := range fields {
+		name := prefix + field.name
+		target := value.Field(field.index)
+
+		for _, message := range check(target, field.rules) {
+			errors.Add(name, message)
+		}
+		if nested := reflect.Indirect(target); field.nested && nested.IsValid() {
+			if err := validate(nested, name+".", errors); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// compile parses & verifies the "validate" tags of the struct type, cached once compiled.
+// Nested structs are compiled on their own while validating.
+func compile(kind reflect.Type) ([]field, error) {
+	structs.RLock()
+	fields, ok := structs.cache[kind]
+	structs.RUnlock()
+	if ok {
+		return fields, nil
+	}
+
+	for index := 0; index < kind.NumField(); index++ {
+		structField := kind.Field(index)
+		if structField.PkgPath != "" {
+			continue
+		}
+		var f = field{index: index, name: fieldName(structField)}
+		if tag := structField.Tag.Get("validate"); tag != "" && tag != "-" {
+			for _, item := range split(tag) {
+				name, arg := item[0], item[1]
+				if name != "required" {
+					rule, ok := rules[name]
+					if !ok {
+						return nil, fmt.Errorf("form: unknown validation rule %q of %v.%s", name, kind, structField.Name)
+					}
+					if err := rule.accepts(structField.Type, arg); err != nil {
+						return nil, fmt.Errorf("form: invalid validation rule %q of %v.%s: %v", name+"="+arg, kind, structField.Name, err)
+					}
+				}
+				f.rules = append(f.rules, item)
+			}
+		}
+		nested := structField.Type
+		if nested.Kind() == reflect.Ptr {
+			nested = nested.Elem()
+		}
+		f.nested = nested.Kind() == reflect.Struct && nested != timeType && structField.Type != fileType
+		if len(f.rules) > 0 || f.nested {
+			fields = append(fields, f)
+		}
+	}
+
+	structs.Lock()
+	structs.cache[kind] = fields
+	structs.Unlock()
+	return fields, nil
+}
+
+// split returns the name & argument of the rules in the tag.
+func split(tag string) (items [][2]string) {
+	for tag != "" {
+		var item string
+		if strings.HasPrefix(tag, "regexp=") {
+			item, tag = tag, ""
+		} else if index := strings.Index(tag, ","); index >= 0 {
+			item, tag = tag[:index], tag[index+1:]
+		} else {
+			item, tag = tag, ""
+		}
+		if index := strings.Index(item, "="); index >= 0 {
+			items = append(items, [2]string{item[:index], item[index+1:]})
+		} else {
+			items = append(items, [2]string{item, ""})
+		}
+	}
+	return
+}
+
+// check returns the messages of the (compiled) rules the value failed.
+func check(value reflect.Value, items [][2]string) (messages []string) {
+	var empty, absent = isEmpty(value), isAbsent(value)
+	for _, item := range items {
+		if item[0] == "required" {
+			if empty {
+				// the other rules make no sense without value.
+				return []string{"is required"}
+			}
+			continue
+		}
+		if absent {
+			continue
+		}
+		if message := rules[item[0]].check(reflect.Indirect(value), item[1]); message != "" {
+			messages = append(messages, message)
+		}
+	}
+	return
+}
+
+// fieldName returns the name of the field, i.e. "schema" tag, "json" tag or the field name.
+func fieldName(field reflect.StructField) string {
+	for _, key := range []string{"schema", "json"} {
+		if name := strings.Split(field.Tag.Get(key), ",")[0]; name != "" && name != "-" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// isEmpty reports whether the value is zero, blank strings included.
+func isEmpty(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.String:
+		return strings.TrimSpace(value.String()) == ""
+	case reflect.Array, reflect.Map, reflect.Slice:
+		return value.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return value.IsNil() || isEmpty(value.Elem())
+	}
+	return reflect.DeepEqual(value.Interface(), reflect.Zero(value.Type()).Interface())
+}
+
+// isAbsent reports whether no value was given, i.e. blank strings, empty slices/maps & nil
+// pointers, while zero numbers are still subject to the rules.
+func isAbsent(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.String:
+		return strings.TrimSpace(value.String()) == ""
+	case reflect.Array, reflect.Map, reflect.Slice:
+		return value.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return value.IsNil() || isAbsent(value.Elem())
+	}
+	return false
+}
+
+// measure returns the number, or the length (along with its unit) of the value.
+func measure(value reflect.Value) (n float64, unit string) {
+	switch value.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(value.String())), "characters"
+	case reflect.Array, reflect.Map, reflect.Slice:
+		return float64(value.Len()), "items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), ""
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), ""
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), ""
+	}
+	return 0, ""
+}
+
+// measurable verifies the numeric argument & the field type of min/max/length.
+func measurable(kind reflect.Type, arg string) error {
+	if _, err := strconv.ParseFloat(arg, 64); err != nil {
+		return fmt.Errorf("invalid number %q", arg)
+	}
+	if kind.Kind() == reflect.Ptr {
+		kind = kind.Elem()
+	}
+	switch kind.Kind() {
+	case reflect.String, reflect.Array, reflect.Map, reflect.Slice,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return nil
+	}
+	return fmt.Errorf("unsupported type %v", kind)
+}
+
+// number returns the (verified) numeric argument.
+func number(arg string) float64 {
+	n, _ := strconv.ParseFloat(arg, 64)
+	return n
+}
+
+// pattern returns the compiled (cached) regular expression.
+func pattern(expr string) (*regexp.Regexp, error) {
+	patterns.RLock()
+	compiled, ok := patterns.cache[expr]
+	patterns.RUnlock()
+	if !ok {
+		var err error
+		if compiled, err = regexp.Compile(expr); err != nil {
+			return nil, err
+		}
+		patterns.Lock()
+		patterns.cache[expr] = compiled
+		patterns.Unlock()
+	}
+	return compiled, nil
+}
@@ -0,0 +1,122 @@
+package form
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type address struct {
+	City string `schema:"city" validate:"required"`
+}
+
+type signup struct {
+	Username string   `schema:"username" validate:"required,min=3,max=10"`
+	Email    string   `json:"email" validate:"required,email"`
+	Code     string   `validate:"length=4"`
+	Plan     string   `schema:"plan" validate:"oneof=free pro"`
+	Age      int      `schema:"age" validate:"min=18,max=130"`
+	Tags     []string `schema:"tags" validate:"max=2"`
+	Slug     string   `schema:"slug" validate:"regexp=^[a-z]{1,3}(-[a-z]{1,3})*$"`
+	Address  address  `schema:"address"`
+	ignored  string   `validate:"required"`
+}
+
+type account struct {
+	Password string `validate:"required"`
+	Confirm  string
+}
+
+func (self *account) Validate() error {
+	if self.Password != self.Confirm {
+		return Errors{"Confirm": {"does not match"}}
+	}
+	if self.Password == "password" {
+		return errors.New("password is too weak")
+	}
+	return nil
+}
+
+func TestValidate(t *testing.T) {
+	Convey("rex.form.Validate", t, func() {
+		valid := signup{Username: "rex", Email: "rex@example.com", Plan: "pro", Age: 20, Slug: "a-bc", Address: address{"Paris"}}
+		So(Validate(&valid), ShouldBeNil)
+
+		err := Validate(&signup{Username: "  ", Email: "rex", Code: "12345", Plan: "gold", Age: 7, Tags: []string{"a", "b", "c"}, Slug: "ABC"})
+		So(err, ShouldHaveSameTypeAs, Errors{})
+		So(err.(Errors), ShouldResemble, Errors{
+			"username":     {"is required"},
+			"email":        {"must be a valid email address"},
+			"Code":         {"must be exactly 4 characters"},
+			"plan":         {"must be one of: free, pro"},
+			"age":          {"must be at least 18"},
+			"tags":         {"must be at most 2 items"},
+			"slug":         {"is invalid"},
+			"address.city": {"is required"},
+		})
+
+		err = Validate(&signup{Username: "ab", Email: "rex@example.com", Age: 18, Address: address{"Paris"}})
+		So(err.(Errors), ShouldResemble, Errors{"username": {"must be at least 3 characters"}})
+		So(err.Error(), ShouldEqual, "username: must be at least 3 characters")
+	})
+
+	Convey("rex.form.Validate (zero numbers)", t, func() {
+		type order struct {
+			Quantity int     `validate:"min=1"`
+			Discount float64 `validate:"max=0.5"`
+			Note     *string `validate:"min=3"`
+		}
+		So(Validate(&order{}).(Errors), ShouldResemble, Errors{"Quantity": {"must be at least 1"}})
+		So(Validate(&order{Quantity: 1, Discount: 0.8}).(Errors), ShouldResemble, Errors{"Discount": {"must be at most 0.5"}})
+		So(Validate(&order{Quantity: 1}), ShouldBeNil)
+
+		request, _ := http.NewRequest("GET", "/?name=rex&age=0", nil)
+		var form profile
+		So(Parse(request, &form).(Errors), ShouldResemble, Errors{"age": {"must be at least 18"}})
+	})
+
+	Convey("rex.form.Validate (Validator)", t, func() {
+		So(Validate(&account{Password: "secret", Confirm: "secret"}), ShouldBeNil)
+		So(Validate(&account{Password: "secret"}).(Errors), ShouldResemble, Errors{"Confirm": {"does not match"}})
+		So(Validate(&account{Password: "password", Confirm: "password"}).(Errors), ShouldResemble, Errors{FormKey: {"password is too weak"}})
+		So(Validate(&account{}).(Errors), ShouldResemble, Errors{"Password": {"is required"}})
+	})
+
+	Convey("rex.form.Validate (malformed tags)", t, func() {
+		malformed := []interface{}{
+			&struct {
+				Name string `validate:"unknown"`
+			}{"rex"},
+			&struct {
+				Name string `validate:"min=three"`
+			}{"rex"},
+			&struct {
+				Name string `validate:"regexp=^[a-z"`
+			}{"rex"},
+			&struct {
+				Done bool `validate:"max=1"`
+			}{true},
+			&struct {
+				Name string `validate:"maxsize=1KB"`
+			}{"rex"},
+			&struct {
+				Nested struct {
+					Name string `validate:"oneof"`
+				}
+			}{},
+		}
+		for _, v := range malformed {
+			err := Validate(v)
+			So(err, ShouldNotBeNil)
+			So(err, ShouldNotHaveSameTypeAs, Errors{})
+			So(err.Error(), ShouldStartWith, "form: ")
+		}
+
+		// reported on the live requests as well, rather than panicking.
+		request, _ := http.NewRequest("GET", "/?name=rex", nil)
+		So(func() { Parse(request, malformed[0]) }, ShouldNotPanic)
+		So(Parse(request, malformed[0]), ShouldNotHaveSameTypeAs, Errors{})
+	})
+}
@@ -1,3 +1,11 @@
 package internal
 
 const BaseDir string = "rex.root"
+
+// ReadyFd is the env key of the file descriptor for the new process
+// to report ready to accept connections during a socket handoff.
+const ReadyFd string = "REX_READY_FD"
+
+// Routes is the env key to print the route table of the application
+// in the given format ("json" or "text") instead of serving requests.
+const Routes string = "REX_ROUTES"
@@ -0,0 +1,79 @@
+package internal
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+)
+
+type contextKey struct{}
+
+// Context holds the per-request state shared by rex & its middleware modules.
+type Context struct {
+	Debug bool              // debug mode of the application server.
+	ETag  bool              // weak ETags for the rendered responses.
+	Route string            // name of the matched route.
+	Vars  map[string]string // route variables, available once the route matched.
+
+	// Error responds the failed request via the Error hook of the closest (sub)server.
+	Error func(w http.ResponseWriter, r *http.Request, status int, err error)
+	// Lookup returns the name & variables of the route matching the request ahead of dispatching,
+	// e.g. for the middleware modules of the server running before the route matched.
+	Lookup func(r *http.Request) (route string, vars map[string]string)
+	// Templates renders the HTML templates of the closest (sub)server.
+	Templates interface {
+		ExecuteTemplate(w io.Writer, name string, data interface{}) error
+	}
+
+	mutex    sync.RWMutex
+	values   map[interface{}]interface{}
+	finishes []func()
+}
+
+// Get returns the value stored under the key, nil if absent.
+func (self *Context) Get(key interface{}) interface{} {
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
+	return self.values[key]
+}
+
+// Set stores the value under the key, visible to all handlers of the request.
+func (self *Context) Set(key, value interface{}) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if self.values == nil {
+		self.values = make(map[interface{}]interface{})
+	}
+	self.values[key] = value
+}
+
+// OnFinish registers the function to be executed once the request was served,
+// e.g. removing the temp files of the multipart form.
+func (self *Context) OnFinish(fn func()) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.finishes = append(self.finishes, fn)
+}
+
+// Finish executes the functions registered via OnFinish, in reverse order.
+func (self *Context) Finish() {
+	self.mutex.Lock()
+	finishes := self.finishes
+	self.finishes = nil
+	self.mutex.Unlock()
+	for index := len(finishes) - 1; index >= 0; index-- {
+		finishes[index]()
+	}
+}
+
+// WithContext returns a shallow copy of the request carrying the given Context.
+func WithContext(r *http.Request, ctx *Context) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), contextKey{}, ctx))
+}
+
+// FromRequest returns the Context carried by the request, nil if absent.
+func FromRequest(r *http.Request) *Context {
+	ctx, _ := r.Context().Value(contextKey{}).(*Context)
+	return ctx
+}
@@ -0,0 +1,179 @@
+package rex
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/goanywhere/env"
+)
+
+// listenFdsStart is the first file descriptor passed via LISTEN_FDS (systemd socket activation).
+const listenFdsStart = 3
+
+var (
+	inheritance sync.Once
+	sockets     []net.Listener
+	socketsErr  error
+)
+
+// listen announces on the given address, supports:
+//
+//	"host:port"           TCP address
+//	"unix:/path/to/sock"  Unix domain socket
+//	"fd:3"                inherited file descriptor
+//
+// The nth listener passed via LISTEN_FDS (e.g. systemd, `rex run`, binary upgrade)
+// takes precedence, the first one serves the application, the second one serves
+// the HTTP => HTTPS redirection.
+func listen(address string, nth int) (net.Listener, error) {
+	inheritance.Do(func() {
+		sockets, socketsErr = inherited()
+	})
+	if socketsErr != nil {
+		return nil, socketsErr
+	} else if nth < len(sockets) && sockets[nth] != nil {
+		listener := sockets[nth]
+		sockets[nth] = nil
+		return listener, nil
+	}
+
+	switch {
+	case strings.HasPrefix(address, "unix:"):
+		filename := strings.TrimPrefix(address, "unix:")
+		// remove the stale socket left by previous process, if any.
+		if info, err := os.Stat(filename); err == nil && info.Mode()&os.ModeSocket != 0 {
+			os.Remove(filename)
+		}
+		return net.Listen("unix", filename)
+
+	case strings.HasPrefix(address, "fd:"):
+		fd, err := strconv.Atoi(strings.TrimPrefix(address, "fd:"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid file descriptor: %s", address)
+		}
+		return fileListener(fd)
+
+	default:
+		return net.Listen("tcp", address)
+	}
+}
+
+// inherited returns the listeners passed via LISTEN_FDS, LISTEN_PID (if given)
+// must match the current process to prevent the listeners being taken by children.
+func inherited() (listeners []net.Listener, err error) {
+	count := env.Int("LISTEN_FDS", 0)
+	if count <= 0 {
+		return nil, nil
+	}
+	if pid := env.Int("LISTEN_PID", 0); pid != 0 && pid != os.Getpid() {
+		return nil, nil
+	}
+	// the descriptors are taken, hide them from the child processes.
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_PID")
+	for fd := listenFdsStart; fd < listenFdsStart+count; fd++ {
+		listener, err := fileListener(fd)
+		if err != nil {
+			for _, listener := range listeners {
+				listener.Close()
+			}
+			return nil, err
+		}
+		listeners = append(listeners, listener)
+	}
+	return
+}
+
+// fileListener creates the listener from the inherited file descriptor.
+func fileListener(fd int) (net.Listener, error) {
+	file := os.NewFile(uintptr(fd), fmt.Sprintf("fd:%d", fd))
+	if file == nil {
+		return nil, fmt.Errorf("invalid file descriptor: %d", fd)
+	}
+	// net.FileListener duplicates the descriptor, safe to close the original.
+	defer file.Close()
+	listener, err := net.FileListener(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on fd:%d: %v", fd, err)
+	}
+	return listener, nil
+}
+
+// ConnStats reports the connection metrics of the application server.
+type ConnStats struct {
+	Active   int64  // connections currently open.
+	Accepted uint64 // connections accepted in total.
+	Rejected uint64 // connections rejected due to the MaxConns limit.
+}
+
+// listener tracks the accepted connections, connections beyond the limit
+// (if any) will be closed immediately instead of queueing up.
+type listener struct {
+	net.Listener
+	limit int64
+
+	active   int64
+	accepted uint64
+	rejected uint64
+}
+
+func newListener(inner net.Listener, limit int) *listener {
+	return &listener{Listener: inner, limit: int64(limit)}
+}
+
+func (self *listener) Accept() (net.Conn, error) {
+	for {
+		conn, err := self.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if active := atomic.AddInt64(&self.active, 1); self.limit > 0 && active > self.limit {
+			atomic.AddInt64(&self.active, -1)
+			atomic.AddUint64(&self.rejected, 1)
+			log.Debugf("Connection from %v rejected: %d connections limit reached", conn.RemoteAddr(), self.limit)
+			conn.Close()
+			continue
+		}
+		atomic.AddUint64(&self.accepted, 1)
+		return &connection{Conn: conn, listener: self}, nil
+	}
+}
+
+// stats returns the snapshot of the connection metrics.
+func (self *listener) stats() ConnStats {
+	return ConnStats{
+		Active:   atomic.LoadInt64(&self.active),
+		Accepted: atomic.LoadUint64(&self.accepted),
+		Rejected: atomic.LoadUint64(&self.rejected),
+	}
+}
+
+// connection releases its slot from the listener once closed.
+type connection struct {
+	net.Conn
+	listener *listener
+	once     sync.Once
+}
+
+func (self *connection) Close() error {
+	err := self.Conn.Close()
+	self.once.Do(func() {
+		atomic.AddInt64(&self.listener.active, -1)
+	})
+	return err
+}
+
+// ReadFrom keeps the sendfile/splice support of the underlying connection (e.g. *net.TCPConn).
+func (self *connection) ReadFrom(reader io.Reader) (int64, error) {
+	if conn, ok := self.Conn.(io.ReaderFrom); ok {
+		return conn.ReadFrom(reader)
+	}
+	return io.Copy(self.Conn, reader)
+}
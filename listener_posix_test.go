@@ -0,0 +1,34 @@
+//go:build !windows
+// +build !windows
+
+package rex
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestListenFd(t *testing.T) {
+	Convey("rex.listen (fd:N)", t, func() {
+		listener, err := listen("127.0.0.1:0", 0)
+		So(err, ShouldBeNil)
+		defer listener.Close()
+
+		// hand over a duplicated descriptor, as it will be closed by listen,
+		// while the one owned by the *os.File would be closed again once collected.
+		file, err := listener.(*net.TCPListener).File()
+		So(err, ShouldBeNil)
+		defer file.Close()
+		fd, err := syscall.Dup(int(file.Fd()))
+		So(err, ShouldBeNil)
+
+		inherited, err := listen(fmt.Sprintf("fd:%d", fd), 0)
+		So(err, ShouldBeNil)
+		defer inherited.Close()
+		So(inherited.Addr().String(), ShouldEqual, listener.Addr().String())
+	})
+}
@@ -0,0 +1,130 @@
+package rex
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestListener(t *testing.T) {
+	Convey("rex.listener", t, func() {
+		inner, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		listener := newListener(inner, 1)
+		defer listener.Close()
+
+		accepted := make(chan net.Conn)
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				accepted <- conn
+			}
+		}()
+
+		first, err := net.Dial("tcp", inner.Addr().String())
+		So(err, ShouldBeNil)
+		defer first.Close()
+		conn := <-accepted
+		So(listener.stats().Active, ShouldEqual, 1)
+
+		// beyond the limit: closed by the listener.
+		second, err := net.Dial("tcp", inner.Addr().String())
+		So(err, ShouldBeNil)
+		second.SetReadDeadline(time.Now().Add(time.Second))
+		_, err = ioutil.ReadAll(second)
+		So(err, ShouldBeNil)
+		second.Close()
+
+		stats := listener.stats()
+		So(stats.Accepted, ShouldEqual, 1)
+		So(stats.Rejected, ShouldEqual, 1)
+
+		// slot released once closed.
+		conn.Close()
+		conn.Close()
+		So(listener.stats().Active, ShouldEqual, 0)
+
+		third, err := net.Dial("tcp", inner.Addr().String())
+		So(err, ShouldBeNil)
+		defer third.Close()
+		(<-accepted).Close()
+		So(listener.stats().Accepted, ShouldEqual, 2)
+	})
+
+	Convey("rex.connection (io.ReaderFrom)", t, func() {
+		inner, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		listener := newListener(inner, 0)
+		defer listener.Close()
+
+		client, err := net.Dial("tcp", inner.Addr().String())
+		So(err, ShouldBeNil)
+		defer client.Close()
+		conn, err := listener.Accept()
+		So(err, ShouldBeNil)
+
+		from, ok := conn.(io.ReaderFrom)
+		So(ok, ShouldBeTrue)
+		file, err := ioutil.TempFile("", "rex")
+		So(err, ShouldBeNil)
+		defer os.Remove(file.Name())
+		defer file.Close()
+		file.WriteString("sendfile")
+		file.Seek(0, io.SeekStart)
+
+		size, err := from.ReadFrom(file)
+		So(err, ShouldBeNil)
+		So(size, ShouldEqual, 8)
+		conn.Close()
+		data, err := ioutil.ReadAll(client)
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, "sendfile")
+		So(listener.stats().Active, ShouldEqual, 0)
+	})
+}
+
+func TestListen(t *testing.T) {
+	Convey("rex.listen", t, func() {
+		listener, err := listen("127.0.0.1:0", 0)
+		So(err, ShouldBeNil)
+		So(listener.Addr().Network(), ShouldEqual, "tcp")
+
+		listener.Close()
+
+		_, err = listen("fd:socket", 0)
+		So(err, ShouldNotBeNil)
+
+		// unix domain socket, stale socket file should be replaced.
+		dir, _ := ioutil.TempDir("", "rex")
+		defer os.RemoveAll(dir)
+		address := "unix:" + filepath.Join(dir, "app.sock")
+		for index := 0; index < 2; index++ {
+			listener, err = listen(address, 0)
+			So(err, ShouldBeNil)
+			So(listener.Addr().Network(), ShouldEqual, "unix")
+			listener.(*net.UnixListener).SetUnlinkOnClose(false)
+			listener.Close()
+		}
+	})
+
+	Convey("rex.inherited", t, func() {
+		os.Setenv("LISTEN_FDS", "1")
+		os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+		defer os.Unsetenv("LISTEN_FDS")
+		defer os.Unsetenv("LISTEN_PID")
+
+		listeners, err := inherited()
+		So(err, ShouldBeNil)
+		So(listeners, ShouldBeEmpty)
+	})
+}
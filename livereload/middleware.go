@@ -22,8 +22,17 @@ func (self *writer) addJavaScript(data []byte) []byte {
 	return regexp.MustCompile(`</head>`).ReplaceAll(data, []byte(javascript))
 }
 
+// WriteHeader drops the Content-Length of HTML responses, which changes once the javascript is added.
+func (self *writer) WriteHeader(status int) {
+	if strings.Contains(self.Header().Get("Content-Type"), "html") {
+		self.Header().Del("Content-Length")
+	}
+	self.ResponseWriter.WriteHeader(status)
+}
+
 func (self *writer) Write(data []byte) (size int, e error) {
 	if strings.Contains(self.Header().Get("Content-Type"), "html") {
+		self.Header().Del("Content-Length")
 		var encoding = self.Header().Get("Content-Encoding")
 		if encoding == "" {
 			data = self.addJavaScript(data)
@@ -0,0 +1,30 @@
+package livereload
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/goanywhere/rex"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMiddleware(t *testing.T) {
+	app := rex.New()
+	app.Use(Middleware)
+	app.Templates(template.Must(template.New("index.html").Parse(`<html><head><title>{{.}}</title></head></html>`)))
+	app.Get("/", func(w http.ResponseWriter, r *http.Request) error {
+		return rex.HTML(w, r, http.StatusOK, "index.html", "rex")
+	})
+
+	Convey("rex.livereload.Middleware (rendered)", t, func() {
+		request, _ := http.NewRequest("GET", "http://localhost:5000/", nil)
+		response := httptest.NewRecorder()
+		app.ServeHTTP(response, request)
+
+		So(response.Code, ShouldEqual, http.StatusOK)
+		So(response.Result().Header.Get("Content-Length"), ShouldBeEmpty)
+		So(response.Body.String(), ShouldContainSubstring, `<title>rex</title><script defer src="//localhost:5000`+URL.JavaScript+`"></script>`)
+	})
+}
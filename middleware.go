@@ -3,15 +3,19 @@ package rex
 import "net/http"
 
 type middleware struct {
-	cache http.Handler
-	stack []func(http.Handler) http.Handler
+	cache   http.Handler
+	handler http.Handler // final http.Handler, defaults to http.NotFoundHandler.
+	stack   []func(http.Handler) http.Handler
 }
 
 // Implements the net/http Handler interface and calls the middleware stack.
 func (self *middleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if self.cache == nil {
 		// setup the whole middleware modules in a FIFO chain.
-		var next http.Handler = http.DefaultServeMux
+		var next http.Handler = http.NotFoundHandler()
+		if self.handler != nil {
+			next = self.handler
+		}
 		for index := len(self.stack) - 1; index >= 0; index-- {
 			next = self.stack[index](next)
 		}
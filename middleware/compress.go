@@ -22,6 +22,8 @@ type compression interface {
 type compressor struct {
 	http.ResponseWriter
 	encodings []string
+	status    int  // status code deferred until the body is filtered.
+	written   bool // status code written to the underlying http.ResponseWriter.
 }
 
 // AcceptEncodings fetches the requested encodings from client with priority.
@@ -88,13 +90,32 @@ func (self *compressor) filter(src []byte) ([]byte, string) {
 	return src, ""
 }
 
+// WriteHeader defers the status code, so the headers can still be changed once the body is filtered.
+func (self *compressor) WriteHeader(status int) {
+	if self.status == 0 {
+		self.status = status
+	}
+}
+
+// writeHeader writes the deferred status code (if any) to the underlying http.ResponseWriter.
+func (self *compressor) writeHeader() {
+	if !self.written {
+		self.written = true
+		if self.status != 0 {
+			self.ResponseWriter.WriteHeader(self.status)
+		}
+	}
+}
+
 func (self *compressor) Write(data []byte) (size int, err error) {
 	if bytes, encoding := self.filter(data); encoding != "" {
 		self.Header().Set("Content-Encoding", encoding)
 		self.Header().Add("Vary", "Accept-Encoding")
 		self.Header().Del("Content-Length")
+		self.writeHeader()
 		return self.ResponseWriter.Write(bytes)
 	}
+	self.writeHeader()
 	return self.ResponseWriter.Write(data)
 }
 
@@ -113,6 +134,8 @@ func Compress(next http.Handler) http.Handler {
 			} else {
 				compressor.encodings = encodings
 				next.ServeHTTP(compressor, r)
+				// e.g. 304 Not Modified without body.
+				compressor.writeHeader()
 			}
 		}
 	})
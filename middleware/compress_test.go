@@ -1,7 +1,9 @@
 package middleware
 
 import (
+	"compress/gzip"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -28,3 +30,35 @@ func TestCompress(t *testing.T) {
 		So(response.Header().Get("Content-Encoding"), ShouldEqual, "gzip")
 	})
 }
+
+func TestCompressRendered(t *testing.T) {
+	app := rex.New()
+	app.Use(Compress)
+	app.Get("/", func(w http.ResponseWriter, r *http.Request) error {
+		return rex.Render(w, r, http.StatusOK, rex.M{"name": "rex"})
+	})
+
+	Convey("rex.middleware.Compress (rendered)", t, func() {
+		request, _ := http.NewRequest("GET", "/", nil)
+		request.Header.Set("Accept-Encoding", "gzip")
+		response := httptest.NewRecorder()
+		app.ServeHTTP(response, request)
+
+		So(response.Code, ShouldEqual, http.StatusOK)
+		So(response.Result().Header.Get("Content-Encoding"), ShouldEqual, "gzip")
+		So(response.Result().Header.Get("Content-Length"), ShouldBeEmpty)
+
+		reader, err := gzip.NewReader(response.Body)
+		So(err, ShouldBeNil)
+		body, _ := ioutil.ReadAll(reader)
+		So(string(body), ShouldContainSubstring, `"name": "rex"`)
+
+		// 304 Not Modified
+		request.Header.Set("If-None-Match", response.Header().Get("ETag"))
+		response = httptest.NewRecorder()
+		app.ServeHTTP(response, request)
+		So(response.Code, ShouldEqual, http.StatusNotModified)
+		So(response.Result().Header.Get("Content-Encoding"), ShouldBeEmpty)
+		So(response.Body.Len(), ShouldEqual, 0)
+	})
+}
@@ -5,12 +5,20 @@ import (
 	"time"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/goanywhere/rex/internal"
 )
 
+// StartTimeKey is the key of the request start time published by Logger,
+// e.g. rex.GetTime(r, middleware.StartTimeKey).
+const StartTimeKey = "rex.start"
+
 // Logger renders the simple HTTP accesses logs for the upcoming http.Handler.
 func Logger(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
+		if ctx := internal.FromRequest(r); ctx != nil {
+			ctx.Set(StartTimeKey, start)
+		}
 		next.ServeHTTP(w, r)
 		logrus.Debugf("%s - %s (%v)", r.Method, r.URL.Path, time.Since(start))
 	})
@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/goanywhere/rex/internal"
+)
+
+var debugPage = template.Must(template.New("debug").Parse(`<!DOCTYPE html>
+<html>
+<head><title>500 Internal Server Error</title></head>
+<body>
+<h1>panic: {{.Panic}}</h1>
+<p>{{.Method}} {{.URL}}</p>
+<h2>Route Variables</h2>
+<table>{{range $key, $value := .Vars}}<tr><th>{{$key}}</th><td>{{$value}}</td></tr>{{end}}</table>
+<h2>Request Headers</h2>
+<table>{{range $key, $values := .Header}}{{range $values}}<tr><th>{{$key}}</th><td>{{.}}</td></tr>{{end}}{{end}}</table>
+<h2>Stack Trace</h2>
+<pre>{{.Stack}}</pre>
+</body>
+</html>
+`))
+
+// sensitiveHeaders are never shown on the debug page.
+var sensitiveHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization"}
+
+// redact returns a copy of the request headers with the sensitive values masked.
+func redact(header http.Header) http.Header {
+	var redacted = header.Clone()
+	for _, key := range sensitiveHeaders {
+		if _, exists := redacted[key]; exists {
+			redacted[key] = []string{"[redacted]"}
+		}
+	}
+	return redacted
+}
+
+// Recover recovers the panics from the upcoming http.Handler, logs the stack trace along with
+// the request details and responds 500 Internal Server Error, a debug page with the stack trace,
+// request headers (credentials redacted) & route variables is rendered instead in debug mode only.
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if value := recover(); value != nil {
+				if value == http.ErrAbortHandler {
+					// deliberate abort, let net/http handle it silently.
+					panic(value)
+				}
+				stack := debug.Stack()
+				logrus.WithFields(logrus.Fields{
+					"method": r.Method,
+					"url":    r.URL.String(),
+					"remote": r.RemoteAddr,
+				}).Errorf("panic: %v\n%s", value, stack)
+
+				ctx := internal.FromRequest(r)
+				switch {
+				case ctx != nil && ctx.Debug:
+					w.Header().Set("Content-Type", "text/html; charset=utf-8")
+					w.WriteHeader(http.StatusInternalServerError)
+					debugPage.Execute(w, map[string]interface{}{
+						"Panic":  fmt.Sprint(value),
+						"Method": r.Method,
+						"URL":    r.URL.String(),
+						"Header": redact(r.Header),
+						"Vars":   ctx.Vars,
+						"Stack":  string(stack),
+					})
+
+				case ctx != nil && ctx.Error != nil:
+					ctx.Error(w, r, http.StatusInternalServerError, fmt.Errorf("panic: %v", value))
+
+				default:
+					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				}
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/goanywhere/rex"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRecover(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		panic("something <bad> happened")
+	}
+
+	Convey("rex.middleware.Recover (debug)", t, func() {
+		config := rex.NewConfig()
+		config.Debug = true
+		app := rex.NewWithConfig(config)
+		app.Use(Recover)
+		app.Get("/users/{id}", handler)
+
+		request, _ := http.NewRequest("GET", "/users/42", nil)
+		request.Header.Set("X-Request-Id", "abc")
+		request.Header.Set("Authorization", "Bearer secret-token")
+		request.Header.Set("Cookie", "session=secret-session")
+		response := httptest.NewRecorder()
+		app.ServeHTTP(response, request)
+
+		So(response.Code, ShouldEqual, http.StatusInternalServerError)
+		So(response.Header().Get("Content-Type"), ShouldEqual, "text/html; charset=utf-8")
+		body := response.Body.String()
+		So(body, ShouldContainSubstring, "panic: something &lt;bad&gt; happened")
+		So(body, ShouldContainSubstring, "<tr><th>id</th><td>42</td></tr>")
+		So(body, ShouldContainSubstring, "<tr><th>X-Request-Id</th><td>abc</td></tr>")
+		So(body, ShouldContainSubstring, "<tr><th>Authorization</th><td>[redacted]</td></tr>")
+		So(body, ShouldContainSubstring, "<tr><th>Cookie</th><td>[redacted]</td></tr>")
+		So(body, ShouldNotContainSubstring, "secret")
+		So(request.Header.Get("Cookie"), ShouldEqual, "session=secret-session")
+		So(body, ShouldContainSubstring, "recover_test.go")
+	})
+
+	Convey("rex.middleware.Recover (production)", t, func() {
+		config := rex.NewConfig()
+		config.Debug = false
+		app := rex.NewWithConfig(config)
+		app.Use(Recover)
+		app.Get("/users/{id}", handler)
+
+		request, _ := http.NewRequest("GET", "/users/42", nil)
+		request.Header.Set("Accept", "application/json")
+		response := httptest.NewRecorder()
+		app.ServeHTTP(response, request)
+
+		So(response.Code, ShouldEqual, http.StatusInternalServerError)
+		So(response.Body.String(), ShouldEqual, `{"status":500,"message":"Internal Server Error"}`+"\n")
+	})
+
+	Convey("rex.middleware.Recover (http.ErrAbortHandler)", t, func() {
+		aborted := Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic(http.ErrAbortHandler)
+		}))
+		request, _ := http.NewRequest("GET", "/", nil)
+		So(func() { aborted.ServeHTTP(httptest.NewRecorder(), request) }, ShouldPanicWith, http.ErrAbortHandler)
+	})
+}
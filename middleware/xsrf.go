@@ -3,160 +3,433 @@ package middleware
 import (
 	"bytes"
 	"crypto/hmac"
-	"crypto/sha1"
+	"crypto/rand"
+	"crypto/sha256"
 	"crypto/subtle"
 	"encoding/base64"
-	"encoding/hex"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
 	"net/http"
 	"net/url"
-	"regexp"
-	"strconv"
+	"strings"
+	"sync"
 	"time"
 
-	"github.com/goanywhere/crypto"
+	logrus "github.com/Sirupsen/logrus"
+	"github.com/goanywhere/env"
+	"github.com/goanywhere/rex/internal"
 )
 
-const (
-	xsrfCookieName = "xsrf"
-	xsrfHeaderName = "X-XSRF-Token"
-	xsrfFieldName  = "xsrftoken"
+// XSRFTokenKey is the key of the XSRF token published by XSRF,
+// e.g. rex.GetString(r, middleware.XSRFTokenKey).
+const XSRFTokenKey = "rex.xsrf"
+
+// XSRFFieldName is the default name of the form field carrying the XSRF token.
+const XSRFFieldName = "xsrftoken"
+
+// xsrfFieldKey is the key of the form field name published by XSRF, see XSRFField.
+const xsrfFieldKey = "rex.xsrf.field"
 
-	xsrfMaxAge  = 3600 * 24 * 365
-	xsrfTimeout = time.Hour * 24 * 365
+const (
+	xsrfSecretSize = 32          // random secret of the token.
+	xsrfIssuedSize = 8           // issued time of the secret, in unix nanoseconds.
+	xsrfSignSize   = sha256.Size // HMAC-SHA256 of the secret & issued time.
+	xsrfCookieSize = xsrfSecretSize + xsrfIssuedSize + xsrfSignSize
+	xsrfScanSize   = 1 << 20 // maximum bytes of the body scanned for the form field.
 )
 
 var (
-	errXSRFReferer = "Referer URL is missing from the request or the value was malformed."
-	errXSRFToken   = "Invalid XSRF tokens"
+	// ErrXSRFOrigin rejects the request of missing/untrusted Origin (or Referer under HTTPS).
+	ErrXSRFOrigin = errors.New("xsrf: Origin/Referer is missing, malformed or not trusted")
+	// ErrXSRFToken rejects the request of missing/invalid/expired XSRF token.
+	ErrXSRFToken = errors.New("xsrf: invalid token")
+
+	encoding = base64.RawURLEncoding
 
-	xsrfPattern   = regexp.MustCompile("[^0-9a-zA-Z-_]")
-	unsafeMethods = regexp.MustCompile("^(DELETE|POST|PUT)$")
+	fallback struct {
+		sync.Once
+		key string
+	}
 )
 
-type xsrf struct {
-	*http.Request
-	http.ResponseWriter
-	token string
+// XSRFOptions configures the XSRF middleware, zero values fall back to the defaults.
+type XSRFOptions struct {
+	CookieName string        // name of the cookie carrying the token, defaults to "xsrf".
+	Path       string        // path of the cookie, defaults to "/".
+	Domain     string        // domain of the cookie, defaults to the request host.
+	Secure     bool          // send the cookie over HTTPS only.
+	SameSite   http.SameSite // SameSite attribute of the cookie.
+	HeaderName string        // header carrying the token, defaults to "X-XSRF-Token".
+	FieldName  string        // form field carrying the token, defaults to XSRFFieldName.
+	MaxAge     time.Duration // lifetime of the token, defaults to a year.
+	Methods    []string      // methods to check, defaults to DELETE, PATCH, POST & PUT.
+
+	// SecretKeys sign the tokens, the first one signs while all of them verify, thus the
+	// keys can be rotated by prepending the new ones. Defaults to the comma separated keys
+	// of the Rex_Secret_Keys env (generated by `rex new`), random ones otherwise.
+	SecretKeys []string
+
+	// TrustedOrigins are the hosts (along with the port if any) allowed in the Origin/Referer
+	// header besides the request host, e.g. "app.example.com".
+	TrustedOrigins []string
+	// ExemptPaths are the URL paths not to check, those ending with "/" match as prefixes.
+	ExemptPaths []string
+	// ExemptRoutes are the names of the routes not to check, e.g. "POST:/webhooks/{id}".
+	ExemptRoutes []string
+
+	// Failure responds the rejected request with ErrXSRFOrigin/ErrXSRFToken,
+	// defaults to 403 Forbidden via the Error hook (if any).
+	Failure func(w http.ResponseWriter, r *http.Request, err error)
 }
 
-// See http://en.wikipedia.org/wiki/Same-origin_policy
-func (self *xsrf) checkOrigin() bool {
-	if self.Request.URL.Scheme == "https" {
-		// See [OWASP]; Checking the Referer Header.
-		referer, err := url.Parse(self.Request.Header.Get("Referer"))
+// defaults fills the zero values with the defaults.
+func (self XSRFOptions) defaults() XSRFOptions {
+	if self.CookieName == "" {
+		self.CookieName = "xsrf"
+	}
+	if self.Path == "" {
+		self.Path = "/"
+	}
+	if self.HeaderName == "" {
+		self.HeaderName = "X-XSRF-Token"
+	}
+	if self.FieldName == "" {
+		self.FieldName = XSRFFieldName
+	}
+	if self.MaxAge <= 0 {
+		self.MaxAge = time.Hour * 24 * 365
+	}
+	if self.Methods == nil {
+		self.Methods = []string{"DELETE", "PATCH", "POST", "PUT"}
+	}
+	if self.SecretKeys == nil {
+		for _, key := range strings.Split(env.String("Rex_Secret_Keys", ""), ",") {
+			if key = strings.TrimSpace(key); key != "" {
+				self.SecretKeys = append(self.SecretKeys, key)
+			}
+		}
+	}
+	if len(self.SecretKeys) == 0 {
+		self.SecretKeys = []string{fallbackKey()}
+	}
+	if self.Failure == nil {
+		self.Failure = xsrfFailure
+	}
+	return self
+}
 
-		if err != nil || referer.String() == "" ||
-			referer.Scheme != self.Request.URL.Scheme ||
-			referer.Host != self.Request.URL.Host {
+// fallbackKey returns the random key shared by all XSRF modules of the process,
+// in case no secret keys are configured.
+func fallbackKey() string {
+	fallback.Do(func() {
+		logrus.Warn("xsrf: no secret keys (Rex_Secret_Keys) found, tokens are invalidated once restarted")
+		fallback.key = string(random(xsrfSignSize))
+	})
+	return fallback.key
+}
 
+// unsafe reports whether the request is subject to the checks.
+func (self *XSRFOptions) unsafe(r *http.Request) bool {
+	if !contains(self.Methods, r.Method) {
+		return false
+	}
+	for _, path := range self.ExemptPaths {
+		if r.URL.Path == path || strings.HasSuffix(path, "/") && strings.HasPrefix(r.URL.Path, path) {
 			return false
 		}
 	}
+	if len(self.ExemptRoutes) > 0 {
+		if ctx := internal.FromRequest(r); ctx != nil {
+			route := ctx.Route
+			if route == "" && ctx.Lookup != nil {
+				// the server middleware runs before the route matched.
+				route, _ = ctx.Lookup(r)
+			}
+			if route != "" && contains(self.ExemptRoutes, route) {
+				return false
+			}
+		}
+	}
 	return true
 }
 
-func (self *xsrf) checkToken(token string) bool {
-	// Header always takes precedance of form field since some popular
-	// JavaScript frameworks allow global custom headers for all AJAX requests.
-	query := self.Request.Header.Get(xsrfFieldName)
-	if query == "" {
-		query = self.Request.FormValue(xsrfFieldName)
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
 	}
+	return false
+}
 
-	// 1) basic length comparison.
-	if query == "" || len(query) != len(token) {
-		return false
+// xsrfFailure responds 403 Forbidden via the Error hook of the server, plain text otherwise.
+func xsrfFailure(w http.ResponseWriter, r *http.Request, err error) {
+	if ctx := internal.FromRequest(r); ctx != nil && ctx.Error != nil {
+		ctx.Error(w, r, http.StatusForbidden, err)
+	} else {
+		http.Error(w, err.Error(), http.StatusForbidden)
 	}
-	// *sanitize* incoming masked token.
-	query = xsrfPattern.ReplaceAllString(query, "")
+}
 
-	// 2) byte-based comparison.
-	a, _ := base64.URLEncoding.DecodeString(token)
-	b, _ := base64.URLEncoding.DecodeString(query)
-	if subtle.ConstantTimeCompare(a, b) != 1 {
-		return false
-	}
+// xsrf protects the request with the double submit of the secret: the signed secret is
+// kept in the cookie, while the secret masked with one-time pad per request (against BREACH)
+// is submitted via the header or form field.
+type xsrf struct {
+	*http.Request
+	http.ResponseWriter
+	*XSRFOptions
+	secret []byte // secret of the valid cookie, nil if missing/invalid.
+	token  string // secret masked for this request.
+}
 
-	// 3) issued time checking.
-	index := bytes.LastIndex(b, []byte{'|'})
-	if index != 40 {
+// See http://en.wikipedia.org/wiki/Same-origin_policy
+func (self *xsrf) checkOrigin() bool {
+	var source = self.Request.Header.Get("Origin")
+	if source == "" {
+		if self.Request.TLS == nil && self.Request.URL.Scheme != "https" {
+			return true
+		}
+		// See [OWASP]; Checking the Referer Header.
+		source = self.Request.Header.Get("Referer")
+	}
+	origin, err := url.Parse(source)
+	if err != nil || origin.Host == "" {
 		return false
 	}
+	if origin.Host == self.Request.Host {
+		// the same host over plain HTTP is not the same origin of HTTPS.
+		return origin.Scheme == "https" || self.Request.TLS == nil && self.Request.URL.Scheme != "https"
+	}
+	return contains(self.TrustedOrigins, origin.Host)
+}
 
-	nanos, err := strconv.ParseInt(string(b[index+1:]), 10, 64)
-	if err != nil {
+func (self *xsrf) checkToken() bool {
+	if self.secret == nil {
 		return false
 	}
-	now := time.Now()
-	issueTime := time.Unix(0, nanos)
+	// Header always takes precedance of form field since some popular
+	// JavaScript frameworks allow global custom headers for all AJAX requests.
+	token := self.Request.Header.Get(self.HeaderName)
+	if token == "" {
+		token = self.formToken()
+	}
+	secret := unmask(token)
+	return secret != nil && subtle.ConstantTimeCompare(secret, self.secret) == 1
+}
 
-	if now.Sub(issueTime) >= xsrfTimeout {
-		return false
+// formToken returns the token of the form field, the body is scanned up to xsrfScanSize
+// without being consumed, thus the handlers (e.g. form.Parse) still apply their own limits.
+func (self *xsrf) formToken() string {
+	r := self.Request
+	if r.PostForm != nil {
+		// parsed upstream already.
+		return r.FormValue(self.FieldName)
 	}
+	if r.Body != nil && r.Body != http.NoBody {
+		mediatype, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		var buffer bytes.Buffer
+		var reader = io.TeeReader(io.LimitReader(r.Body, xsrfScanSize), &buffer)
+		var token string
+		switch mediatype {
+		case "application/x-www-form-urlencoded":
+			data, _ := ioutil.ReadAll(reader)
+			values, _ := url.ParseQuery(string(data))
+			token = values.Get(self.FieldName)
 
-	// Ensure the token is not from the *future*, allow 1 minute grace period.
-	if issueTime.After(now.Add(1 * time.Minute)) {
-		return false
+		case "multipart/form-data":
+			parts := multipart.NewReader(reader, params["boundary"])
+			for token == "" {
+				part, err := parts.NextPart()
+				if err != nil {
+					break
+				}
+				if part.FormName() == self.FieldName && part.FileName() == "" {
+					data, _ := ioutil.ReadAll(io.LimitReader(part, xsrfCookieSize*2))
+					token = string(data)
+				}
+			}
+		}
+		// restore the scanned bytes for the handlers.
+		r.Body = &replay{Reader: io.MultiReader(&buffer, r.Body), Closer: r.Body}
+		if token != "" {
+			return token
+		}
 	}
+	return r.URL.Query().Get(self.FieldName)
+}
 
-	return true
+// replay reads the scanned bytes of the body again, followed by the rest.
+type replay struct {
+	io.Reader
+	io.Closer
 }
 
-func (self *xsrf) generate() {
-	// Ensure we have XSRF token in the cookie first.
-	var token string
-	if cookie, err := self.Request.Cookie(xsrfCookieName); err == nil {
-		if cookie.Value != "" {
-			token = cookie.Value
+// verify returns the secret & issued time of the signed cookie value, along with the index
+// of the key signed it, -1 if malformed, forged or expired.
+func (self *xsrf) verify(value string) (secret []byte, issued time.Time, index int) {
+	data, err := encoding.DecodeString(value)
+	if err != nil || len(data) != xsrfCookieSize {
+		return nil, issued, -1
+	}
+	payload, signature := data[:xsrfSecretSize+xsrfIssuedSize], data[xsrfSecretSize+xsrfIssuedSize:]
+	for index, key := range self.SecretKeys {
+		if hmac.Equal(signature, sign(key, payload)) {
+			issued = time.Unix(0, int64(binary.BigEndian.Uint64(payload[xsrfSecretSize:])))
+			now := time.Now()
+			// Ensure the token is not from the *future*, allow 1 minute grace period.
+			if now.Sub(issued) >= self.MaxAge || issued.After(now.Add(time.Minute)) {
+				break
+			}
+			return payload[:xsrfSecretSize], issued, index
 		}
 	}
-	if token == "" {
-		// Generate a base64-encoded token.
-		nano := time.Now().UnixNano()
-		hash := hmac.New(sha1.New, []byte(crypto.Random(32)))
-		fmt.Fprintf(hash, "%s|%d", crypto.Random(12), nano)
-		raw := fmt.Sprintf("%s|%d", hex.EncodeToString(hash.Sum(nil)), nano)
-		token = base64.URLEncoding.EncodeToString([]byte(raw))
+	return nil, issued, -1
+}
+
+// generate ensures the signed secret in the cookie (re-signed with the current key once
+// rotated), then masks it as the token of this request.
+func (self *xsrf) generate() {
+	var secret []byte
+	var issued time.Time
+	var index = -1
+	if cookie, err := self.Request.Cookie(self.CookieName); err == nil {
+		secret, issued, index = self.verify(cookie.Value)
+	}
+	// only the secret from the request protects it.
+	self.secret = secret
+	if secret == nil {
+		secret, issued = random(xsrfSecretSize), time.Now()
+	}
+	if index != 0 {
+		var payload = make([]byte, xsrfSecretSize+xsrfIssuedSize)
+		copy(payload, secret)
+		binary.BigEndian.PutUint64(payload[xsrfSecretSize:], uint64(issued.UnixNano()))
 
 		// The max-age directive takes priority over Expires.
 		//	http://www.w3.org/Protocols/rfc2616/rfc2616-sec13.html
 		cookie := new(http.Cookie)
-		cookie.Name = xsrfCookieName
-		cookie.Value = token
-		cookie.MaxAge = xsrfMaxAge
-		cookie.Path = "/"
+		cookie.Name = self.CookieName
+		cookie.Value = encoding.EncodeToString(append(payload, sign(self.SecretKeys[0], payload)...))
+		cookie.MaxAge = int(self.MaxAge / time.Second)
+		cookie.Path = self.Path
+		cookie.Domain = self.Domain
+		cookie.Secure = self.Secure
+		cookie.SameSite = self.SameSite
 		cookie.HttpOnly = true
 		http.SetCookie(self.ResponseWriter, cookie)
 	}
-	self.ResponseWriter.Header()[xsrfHeaderName] = []string{token}
-	self.token = token
+	self.token = mask(secret)
+	self.ResponseWriter.Header()[self.HeaderName] = []string{self.token}
+}
+
+// sign returns the HMAC-SHA256 of the data with the key.
+func sign(key string, data []byte) []byte {
+	hash := hmac.New(sha256.New, []byte(key))
+	hash.Write(data)
+	return hash.Sum(nil)
+}
+
+// mask returns the secret XOR-ed with a random one-time pad, prefixed by the pad.
+func mask(secret []byte) string {
+	var pad = random(len(secret))
+	var masked = make([]byte, len(secret))
+	for index := range secret {
+		masked[index] = secret[index] ^ pad[index]
+	}
+	return encoding.EncodeToString(append(pad, masked...))
+}
+
+// unmask returns the secret of the masked token, nil if malformed.
+func unmask(token string) []byte {
+	data, err := encoding.DecodeString(token)
+	if err != nil || len(data) != 2*xsrfSecretSize {
+		return nil
+	}
+	pad, secret := data[:xsrfSecretSize], data[xsrfSecretSize:]
+	for index := range secret {
+		secret[index] ^= pad[index]
+	}
+	return secret
+}
+
+func random(size int) []byte {
+	var data = make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		panic("xsrf: failed to read random bytes: " + err.Error())
+	}
+	return data
 }
 
-// XSRF serves as Cross-Site Request Forgery protection middleware.
+// XSRF serves as Cross-Site Request Forgery protection middleware with the default options.
 func XSRF(next http.Handler) http.Handler {
-	fn := func(w http.ResponseWriter, r *http.Request) {
-		x := new(xsrf)
-		x.Request = r
-		x.ResponseWriter = w
-		x.generate()
-
-		if unsafeMethods.MatchString(r.Method) {
-			// Ensure the URL came for "Referer" under HTTPS.
-			if !x.checkOrigin() {
-				http.Error(w, errXSRFReferer, http.StatusForbidden)
+	return XSRFWith(XSRFOptions{})(next)
+}
+
+// XSRFWith creates the Cross-Site Request Forgery protection middleware with the options, e.g.
+//
+//	app.Use(middleware.XSRFWith(middleware.XSRFOptions{
+//		Secure:       true,
+//		SameSite:     http.SameSiteLaxMode,
+//		ExemptRoutes: []string{"webhooks"},
+//		Failure: func(w http.ResponseWriter, r *http.Request, err error) {
+//			rex.JSON(w, r, http.StatusForbidden, rex.M{"error": err.Error()})
+//		},
+//	}))
+func XSRFWith(options XSRFOptions) func(http.Handler) http.Handler {
+	options = options.defaults()
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			x := &xsrf{Request: r, ResponseWriter: w, XSRFOptions: &options}
+			x.generate()
+			if ctx := internal.FromRequest(r); ctx != nil {
+				ctx.Set(XSRFTokenKey, x.token)
+				ctx.Set(xsrfFieldKey, options.FieldName)
 			}
 
-			// length => bytes => issue time checkpoints.
-			if !x.checkToken(x.token) {
-				http.Error(w, errXSRFToken, http.StatusForbidden)
+			// ensure browser will invalidate the cached XSRF token.
+			w.Header().Add("Vary", "Cookie")
+
+			if options.unsafe(r) {
+				// Ensure the request came from the same or trusted origins.
+				if !x.checkOrigin() {
+					options.Failure(w, r, ErrXSRFOrigin)
+					return
+				}
+
+				// signed cookie => masked token checkpoints.
+				if !x.checkToken() {
+					options.Failure(w, r, ErrXSRFToken)
+					return
+				}
 			}
-		}
 
-		// ensure browser will invalidate the cached XSRF token.
-		w.Header().Add("Vary", "Cookie")
+			next.ServeHTTP(w, r)
+		})
+	}
+}
 
-		next.ServeHTTP(w, r)
+// XSRFField returns the name & value of the form field carrying the XSRF token published
+// by XSRF for the request, the value is empty if XSRF is not in use.
+func XSRFField(r *http.Request) (name, token string) {
+	name = XSRFFieldName
+	if ctx := internal.FromRequest(r); ctx != nil {
+		if field, ok := ctx.Get(xsrfFieldKey).(string); ok {
+			name = field
+		}
+		token, _ = ctx.Get(XSRFTokenKey).(string)
 	}
-	return http.HandlerFunc(fn)
+	return
+}
+
+// XSRFInput returns the hidden input of the form field given by XSRFField.
+func XSRFInput(r *http.Request) template.HTML {
+	name, token := XSRFField(r)
+	return template.HTML(fmt.Sprintf(`<input type="hidden" name="%s" value="%s">`,
+		template.HTMLEscapeString(name), template.HTMLEscapeString(token)))
 }
@@ -0,0 +1,277 @@
+package middleware
+
+import (
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goanywhere/rex"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestXSRFOptions(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		name, token := XSRFField(r)
+		io.WriteString(w, name+"="+token)
+	}
+	// issue returns the XSRF cookie & token of the app.
+	issue := func(app http.Handler) (*http.Cookie, string) {
+		response := httptest.NewRecorder()
+		app.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+		cookie := (&http.Response{Header: response.Header()}).Cookies()[0]
+		return cookie, strings.SplitN(response.Body.String(), "=", 2)[1]
+	}
+
+	Convey("rex.middleware.XSRFWith (cookie & field)", t, func() {
+		app := rex.New()
+		app.Use(XSRFWith(XSRFOptions{
+			CookieName: "csrf",
+			Path:       "/app",
+			Domain:     "example.com",
+			Secure:     true,
+			SameSite:   http.SameSiteStrictMode,
+			HeaderName: "X-CSRF-Token",
+			FieldName:  "csrf",
+			MaxAge:     time.Hour,
+		}))
+		app.Get("/", handler)
+
+		cookie, token := issue(app)
+		So(cookie.Name, ShouldEqual, "csrf")
+		So(cookie.Path, ShouldEqual, "/app")
+		So(cookie.Domain, ShouldEqual, "example.com")
+		So(cookie.Secure, ShouldBeTrue)
+		So(cookie.HttpOnly, ShouldBeTrue)
+		So(cookie.SameSite, ShouldEqual, http.SameSiteStrictMode)
+		So(cookie.MaxAge, ShouldEqual, 3600)
+
+		request := httptest.NewRequest("GET", "/", nil)
+		request.AddCookie(cookie)
+		response := httptest.NewRecorder()
+		app.ServeHTTP(response, request)
+		So(response.Header()["Set-Cookie"], ShouldBeNil)
+		// masked differently per request.
+		So(response.Body.String(), ShouldStartWith, "csrf=")
+		So(response.Body.String(), ShouldNotEqual, "csrf="+token)
+		So(response.Header()["X-CSRF-Token"], ShouldResemble, []string{strings.TrimPrefix(response.Body.String(), "csrf=")})
+		So(unmask(token), ShouldResemble, unmask(response.Header()["X-CSRF-Token"][0]))
+	})
+
+	Convey("rex.middleware.XSRFInput", t, func() {
+		app := rex.New()
+		app.Use(XSRFWith(XSRFOptions{FieldName: `c"srf`}))
+		app.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			_, token := XSRFField(r)
+			io.WriteString(w, string(XSRFInput(r))+"|"+token)
+		})
+
+		response := httptest.NewRecorder()
+		app.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+		parts := strings.SplitN(response.Body.String(), "|", 2)
+		So(parts[0], ShouldEqual, `<input type="hidden" name="c&#34;srf" value="`+parts[1]+`">`)
+		So(string(XSRFInput(httptest.NewRequest("GET", "/", nil))), ShouldEqual, `<input type="hidden" name="xsrftoken" value="">`)
+	})
+
+	Convey("rex.middleware.XSRFWith (methods & exemptions)", t, func() {
+		var failures []error
+		app := rex.New()
+		app.Use(XSRFWith(XSRFOptions{
+			ExemptPaths:  []string{"/hooks/", "/ping"},
+			ExemptRoutes: []string{"upload"},
+			Failure: func(w http.ResponseWriter, r *http.Request, err error) {
+				failures = append(failures, err)
+				rex.JSON(w, r, http.StatusForbidden, rex.M{"error": err.Error()})
+			},
+		}))
+		app.Post("/", handler)
+		app.Post("/hooks/github", handler)
+		app.Post("/ping", handler)
+		app.Post("/pings", handler)
+		app.Post("/upload", handler).Name("upload")
+
+		serve := func(method, path string) *httptest.ResponseRecorder {
+			response := httptest.NewRecorder()
+			app.ServeHTTP(response, httptest.NewRequest(method, path, nil))
+			return response
+		}
+
+		response := serve("POST", "/")
+		So(response.Code, ShouldEqual, http.StatusForbidden)
+		So(response.Header().Get("Content-Type"), ShouldStartWith, "application/json")
+		So(response.Body.String(), ShouldContainSubstring, ErrXSRFToken.Error())
+		So(failures, ShouldResemble, []error{ErrXSRFToken})
+
+		So(serve("POST", "/hooks/github").Code, ShouldEqual, http.StatusOK)
+		So(serve("POST", "/ping").Code, ShouldEqual, http.StatusOK)
+		So(serve("POST", "/pings").Code, ShouldEqual, http.StatusForbidden)
+		So(serve("POST", "/upload").Code, ShouldEqual, http.StatusOK)
+	})
+
+	Convey("rex.middleware.XSRFWith (trusted origins)", t, func() {
+		app := rex.New()
+		app.Use(XSRFWith(XSRFOptions{TrustedOrigins: []string{"app.example.com"}}))
+		app.Get("/", handler)
+		app.Post("/", handler)
+		cookie, token := issue(app)
+
+		serve := func(origin string) int {
+			request := httptest.NewRequest("POST", "https://example.com/", strings.NewReader(url.Values{XSRFFieldName: {token}}.Encode()))
+			request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			request.Header.Set("Origin", origin)
+			request.AddCookie(cookie)
+			response := httptest.NewRecorder()
+			app.ServeHTTP(response, request)
+			return response.Code
+		}
+		So(serve("https://example.com"), ShouldEqual, http.StatusOK)
+		So(serve("https://app.example.com"), ShouldEqual, http.StatusOK)
+		So(serve("http://example.com"), ShouldEqual, http.StatusForbidden)
+		So(serve("https://evil.com"), ShouldEqual, http.StatusForbidden)
+	})
+
+	Convey("rex.middleware.XSRF (default failure)", t, func() {
+		app := rex.New()
+		app.Use(XSRF)
+		var served bool
+		app.Delete("/", func(w http.ResponseWriter, r *http.Request) {
+			served = true
+		})
+		response := httptest.NewRecorder()
+		app.ServeHTTP(response, httptest.NewRequest("DELETE", "/", nil))
+		So(response.Code, ShouldEqual, http.StatusForbidden)
+		So(served, ShouldBeFalse)
+
+		// PATCH is unsafe as well, plain text responded without the Error hook.
+		response = httptest.NewRecorder()
+		XSRF(http.HandlerFunc(handler)).ServeHTTP(response, httptest.NewRequest("PATCH", "/", nil))
+		So(response.Code, ShouldEqual, http.StatusForbidden)
+		So(response.Body.String(), ShouldEqual, ErrXSRFToken.Error()+"\n")
+	})
+}
+
+func TestXSRF(t *testing.T) {
+	var served bool
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		served = true
+	}
+	options := XSRFOptions{SecretKeys: []string{"current", "retired"}}.defaults()
+	app := rex.New()
+	app.Use(XSRFWith(options))
+	app.Get("/", handler)
+	app.Post("/", handler)
+
+	// signed returns the cookie value of the secret signed with the key at the issued time.
+	signed := func(secret []byte, key string, issued time.Time) string {
+		var payload = make([]byte, xsrfSecretSize+xsrfIssuedSize)
+		copy(payload, secret)
+		binary.BigEndian.PutUint64(payload[xsrfSecretSize:], uint64(issued.UnixNano()))
+		return encoding.EncodeToString(append(payload, sign(key, payload)...))
+	}
+	// post serves the POST request with the cookie value, submitted token & origin.
+	post := func(cookie, header, field, origin string) *httptest.ResponseRecorder {
+		served = false
+		request := httptest.NewRequest("POST", "https://example.com/", strings.NewReader(url.Values{XSRFFieldName: {field}}.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.Header.Set("Referer", origin)
+		if cookie != "" {
+			request.AddCookie(&http.Cookie{Name: "xsrf", Value: cookie})
+		}
+		if header != "" {
+			request.Header.Set("X-XSRF-Token", header)
+		}
+		response := httptest.NewRecorder()
+		app.ServeHTTP(response, request)
+		return response
+	}
+	secret := random(xsrfSecretSize)
+	cookie := signed(secret, "current", time.Now())
+
+	Convey("rex.middleware.XSRF (accepted)", t, func() {
+		So(post(cookie, mask(secret), "", "https://example.com/form").Code, ShouldEqual, http.StatusOK)
+		So(served, ShouldBeTrue)
+
+		response := post(cookie, "", mask(secret), "https://example.com/form")
+		So(response.Code, ShouldEqual, http.StatusOK)
+		So(response.Header()["Set-Cookie"], ShouldBeNil)
+		So(served, ShouldBeTrue)
+	})
+
+	Convey("rex.middleware.XSRF (key rotation)", t, func() {
+		response := post(signed(secret, "retired", time.Now()), mask(secret), "", "https://example.com/")
+		So(response.Code, ShouldEqual, http.StatusOK)
+		// re-signed with the current key.
+		cookies := (&http.Response{Header: response.Header()}).Cookies()
+		So(cookies, ShouldHaveLength, 1)
+		value, _, index := (&xsrf{XSRFOptions: &options}).verify(cookies[0].Value)
+		So(value, ShouldResemble, secret)
+		So(index, ShouldEqual, 0)
+	})
+
+	Convey("rex.middleware.XSRF (rejected)", t, func() {
+		rejected := map[string]*httptest.ResponseRecorder{
+			"missing referer":   post(cookie, mask(secret), "", ""),
+			"foreign referer":   post(cookie, mask(secret), "", "https://evil.com/"),
+			"plain referer":     post(cookie, mask(secret), "", "http://example.com/"),
+			"missing cookie":    post("", mask(secret), "", "https://example.com/"),
+			"forged cookie":     post(signed(secret, "unknown", time.Now()), mask(secret), "", "https://example.com/"),
+			"expired cookie":    post(signed(secret, "current", time.Now().Add(-366*24*time.Hour)), mask(secret), "", "https://example.com/"),
+			"future cookie":     post(signed(secret, "current", time.Now().Add(time.Hour)), mask(secret), "", "https://example.com/"),
+			"malformed cookie":  post("!"+cookie, mask(secret), "", "https://example.com/"),
+			"missing token":     post(cookie, "", "", "https://example.com/"),
+			"malformed token":   post(cookie, "!"+mask(secret), "", "https://example.com/"),
+			"unmasked token":    post(cookie, encoding.EncodeToString(secret), "", "https://example.com/"),
+			"mismatched token":  post(cookie, mask(random(xsrfSecretSize)), "", "https://example.com/"),
+			"cookie as token":   post(cookie, cookie, "", "https://example.com/"),
+			"mismatched field":  post(cookie, "", mask(random(xsrfSecretSize)), "https://example.com/"),
+			"header over field": post(cookie, mask(random(xsrfSecretSize)), mask(secret), "https://example.com/"),
+		}
+		for reason, response := range rejected {
+			Convey(reason, func() {
+				So(response.Code, ShouldEqual, http.StatusForbidden)
+			})
+		}
+		// the handler must never run once rejected.
+		post(cookie, "", "", "https://example.com/")
+		So(served, ShouldBeFalse)
+	})
+}
+
+func TestXSRFFallbackKey(t *testing.T) {
+	Convey("rex.middleware.XSRF (shared fallback key)", t, func() {
+		os.Unsetenv("Rex_Secret_Keys")
+		handler := func(w http.ResponseWriter, r *http.Request) {
+			_, token := XSRFField(r)
+			io.WriteString(w, token)
+		}
+		app := rex.New()
+		app.Get("/a", handler, XSRF)
+		app.Post("/a", handler, XSRF)
+		app.Group("/b").Get("/", handler, XSRF)
+
+		response := httptest.NewRecorder()
+		app.ServeHTTP(response, httptest.NewRequest("GET", "/a", nil))
+		cookie := (&http.Response{Header: response.Header()}).Cookies()[0]
+		token := response.Body.String()
+
+		request := httptest.NewRequest("POST", "/a", nil)
+		request.AddCookie(cookie)
+		request.Header.Set("X-XSRF-Token", token)
+		response = httptest.NewRecorder()
+		app.ServeHTTP(response, request)
+		So(response.Code, ShouldEqual, http.StatusOK)
+		So(response.Header()["Set-Cookie"], ShouldBeNil)
+
+		request = httptest.NewRequest("GET", "/b/", nil)
+		request.AddCookie(cookie)
+		response = httptest.NewRecorder()
+		app.ServeHTTP(response, request)
+		So(response.Code, ShouldEqual, http.StatusOK)
+		So(response.Header()["Set-Cookie"], ShouldBeNil)
+	})
+}
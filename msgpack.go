@@ -0,0 +1,238 @@
+package rex
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// msgpack encodes values in MessagePack (https://msgpack.org), the struct fields are
+// named after the "msgpack" tag, the "json" tag or the field name, in order.
+type msgpack struct {
+	*bytes.Buffer
+}
+
+// encodeMsgpack returns the MessagePack encoding of v.
+func encodeMsgpack(v interface{}) ([]byte, error) {
+	encoder := msgpack{new(bytes.Buffer)}
+	if err := encoder.encode(reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	return encoder.Bytes(), nil
+}
+
+func (self msgpack) encode(value reflect.Value) error {
+	if !value.IsValid() {
+		return self.WriteByte(0xc0)
+	}
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+		if value.IsNil() {
+			return self.WriteByte(0xc0)
+		}
+	}
+	if value.CanInterface() {
+		if marshaler, ok := value.Interface().(encoding.TextMarshaler); ok {
+			text, err := marshaler.MarshalText()
+			if err != nil {
+				return err
+			}
+			self.str(string(text))
+			return nil
+		}
+	}
+
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return self.encode(value.Elem())
+
+	case reflect.Bool:
+		if value.Bool() {
+			return self.WriteByte(0xc3)
+		}
+		return self.WriteByte(0xc2)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		self.int(value.Int())
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		self.uint(value.Uint())
+
+	case reflect.Float32:
+		self.WriteByte(0xca)
+		binary.Write(self, binary.BigEndian, math.Float32bits(float32(value.Float())))
+
+	case reflect.Float64:
+		self.WriteByte(0xcb)
+		binary.Write(self, binary.BigEndian, math.Float64bits(value.Float()))
+
+	case reflect.String:
+		self.str(value.String())
+
+	case reflect.Slice, reflect.Array:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			data := make([]byte, value.Len())
+			reflect.Copy(reflect.ValueOf(data), value)
+			self.header(len(data), 0, 0, 0xc4, 0xc5, 0xc6)
+			self.Write(data)
+			return nil
+		}
+		self.header(value.Len(), 0x90, 16, 0, 0xdc, 0xdd)
+		for index := 0; index < value.Len(); index++ {
+			if err := self.encode(value.Index(index)); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Map:
+		keys := value.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		self.header(len(keys), 0x80, 16, 0, 0xde, 0xdf)
+		for _, key := range keys {
+			if err := self.encode(key); err != nil {
+				return err
+			}
+			if err := self.encode(value.MapIndex(key)); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Struct:
+		var names []string
+		var fields []reflect.Value
+		msgpackFields(value, &names, &fields)
+		self.header(len(fields), 0x80, 16, 0, 0xde, 0xdf)
+		for index, field := range fields {
+			self.str(names[index])
+			if err := self.encode(field); err != nil {
+				return err
+			}
+		}
+
+	default:
+		return fmt.Errorf("msgpack: unsupported type: %v", value.Type())
+	}
+	return nil
+}
+
+// msgpackFields collects the exported fields of the struct, embedded structs are flattened.
+func msgpackFields(value reflect.Value, names *[]string, fields *[]reflect.Value) {
+	for index := 0; index < value.NumField(); index++ {
+		field := value.Type().Field(index)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		tag := field.Tag.Get("msgpack")
+		if tag == "" {
+			tag = field.Tag.Get("json")
+		}
+		if tag == "-" {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		if field.Anonymous && parts[0] == "" && field.Type.Kind() == reflect.Struct {
+			msgpackFields(value.Field(index), names, fields)
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		name := parts[0]
+		if name == "" {
+			name = field.Name
+		}
+		if len(parts) > 1 && parts[1] == "omitempty" && isEmpty(value.Field(index)) {
+			continue
+		}
+		*names = append(*names, name)
+		*fields = append(*fields, value.Field(index))
+	}
+}
+
+// isEmpty reports whether the value is empty in terms of "omitempty".
+func isEmpty(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return value.Len() == 0
+	case reflect.Bool:
+		return !value.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return value.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return value.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return value.IsNil()
+	}
+	return false
+}
+
+// header writes the length header in the fixed format (if below the limit) or the 8/16/32-bit formats.
+func (self msgpack) header(length int, fixed byte, limit int, format8, format16, format32 byte) {
+	switch {
+	case length < limit:
+		self.WriteByte(fixed | byte(length))
+	case format8 != 0 && length <= math.MaxUint8:
+		self.WriteByte(format8)
+		self.WriteByte(byte(length))
+	case length <= math.MaxUint16:
+		self.WriteByte(format16)
+		binary.Write(self, binary.BigEndian, uint16(length))
+	default:
+		self.WriteByte(format32)
+		binary.Write(self, binary.BigEndian, uint32(length))
+	}
+}
+
+func (self msgpack) str(value string) {
+	self.header(len(value), 0xa0, 32, 0xd9, 0xda, 0xdb)
+	self.WriteString(value)
+}
+
+func (self msgpack) int(value int64) {
+	switch {
+	case value >= 0:
+		self.uint(uint64(value))
+	case value >= -32:
+		self.WriteByte(byte(value))
+	case value >= math.MinInt8:
+		self.WriteByte(0xd0)
+		self.WriteByte(byte(value))
+	case value >= math.MinInt16:
+		self.WriteByte(0xd1)
+		binary.Write(self, binary.BigEndian, int16(value))
+	case value >= math.MinInt32:
+		self.WriteByte(0xd2)
+		binary.Write(self, binary.BigEndian, int32(value))
+	default:
+		self.WriteByte(0xd3)
+		binary.Write(self, binary.BigEndian, value)
+	}
+}
+
+func (self msgpack) uint(value uint64) {
+	switch {
+	case value <= math.MaxInt8:
+		self.WriteByte(byte(value))
+	case value <= math.MaxUint8:
+		self.WriteByte(0xcc)
+		self.WriteByte(byte(value))
+	case value <= math.MaxUint16:
+		self.WriteByte(0xcd)
+		binary.Write(self, binary.BigEndian, uint16(value))
+	case value <= math.MaxUint32:
+		self.WriteByte(0xce)
+		binary.Write(self, binary.BigEndian, uint32(value))
+	default:
+		self.WriteByte(0xcf)
+		binary.Write(self, binary.BigEndian, value)
+	}
+}
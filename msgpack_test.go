@@ -0,0 +1,59 @@
+package rex
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMsgpack(t *testing.T) {
+	encoded := func(v interface{}) []byte {
+		data, err := encodeMsgpack(v)
+		So(err, ShouldBeNil)
+		return data
+	}
+
+	Convey("rex.encodeMsgpack (scalars)", t, func() {
+		So(encoded(nil), ShouldResemble, []byte{0xc0})
+		So(encoded(true), ShouldResemble, []byte{0xc3})
+		So(encoded(false), ShouldResemble, []byte{0xc2})
+		So(encoded(7), ShouldResemble, []byte{0x07})
+		So(encoded(-1), ShouldResemble, []byte{0xff})
+		So(encoded(-100), ShouldResemble, []byte{0xd0, 0x9c})
+		So(encoded(200), ShouldResemble, []byte{0xcc, 0xc8})
+		So(encoded(-1000), ShouldResemble, []byte{0xd1, 0xfc, 0x18})
+		So(encoded(uint32(70000)), ShouldResemble, []byte{0xce, 0x00, 0x01, 0x11, 0x70})
+		So(encoded(1.5), ShouldResemble, []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0})
+		So(encoded("rex"), ShouldResemble, []byte{0xa3, 'r', 'e', 'x'})
+		So(encoded(strings.Repeat("x", 40))[:2], ShouldResemble, []byte{0xd9, 40})
+		So(encoded([]byte{1, 2}), ShouldResemble, []byte{0xc4, 0x02, 0x01, 0x02})
+		So(encoded(time.Date(2017, 10, 1, 0, 0, 0, 0, time.UTC))[1:], ShouldResemble, []byte("2017-10-01T00:00:00Z"))
+	})
+
+	Convey("rex.encodeMsgpack (containers)", t, func() {
+		So(encoded([]int{1, 2, 3}), ShouldResemble, []byte{0x93, 0x01, 0x02, 0x03})
+		So(encoded([]string(nil)), ShouldResemble, []byte{0xc0})
+		So(encoded(M{"b": 2, "a": 1}), ShouldResemble, []byte{0x82, 0xa1, 'a', 0x01, 0xa1, 'b', 0x02})
+
+		type Base struct {
+			ID int `json:"id"`
+		}
+		type User struct {
+			Base
+			Name     string `msgpack:"name"`
+			Email    string `json:"email,omitempty"`
+			Password string `json:"-"`
+			Admin    bool
+			secret   string
+		}
+		So(encoded(&User{Base: Base{1}, Name: "rex", Password: "secret", secret: "x"}), ShouldResemble,
+			[]byte{0x83, 0xa2, 'i', 'd', 0x01, 0xa4, 'n', 'a', 'm', 'e', 0xa3, 'r', 'e', 'x', 0xa5, 'A', 'd', 'm', 'i', 'n', 0xc2})
+	})
+
+	Convey("rex.encodeMsgpack (unsupported)", t, func() {
+		_, err := encodeMsgpack(make(chan int))
+		So(err, ShouldNotBeNil)
+	})
+}
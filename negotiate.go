@@ -0,0 +1,62 @@
+package rex
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// negotiate returns the offered content type best matching the Accept header of the request,
+// the first offer wins on ties (or without Accept header), empty string if none is acceptable.
+func negotiate(r *http.Request, offers ...string) (best string) {
+	var header = r.Header.Get("Accept")
+	if header == "" {
+		if len(offers) > 0 {
+			return offers[0]
+		}
+		return
+	}
+
+	var quality float64
+	for _, offer := range offers {
+		if q := accepts(header, offer); q > quality {
+			best, quality = offer, q
+		}
+	}
+	return
+}
+
+// accepts returns the quality value of the most specific media range (in the Accept header)
+// matching the content type, 0 if not acceptable.
+func accepts(header, offer string) (quality float64) {
+	var specificity = -1
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		media := strings.ToLower(strings.TrimSpace(params[0]))
+
+		var rank int
+		switch {
+		case media == offer:
+			rank = 2
+		case strings.HasSuffix(media, "/*") && strings.HasPrefix(offer, strings.TrimSuffix(media, "*")):
+			rank = 1
+		case media == "*/*" || media == "*":
+			rank = 0
+		default:
+			continue
+		}
+		if rank <= specificity {
+			continue
+		}
+
+		specificity, quality = rank, 1
+		for _, param := range params[1:] {
+			if kv := strings.SplitN(strings.TrimSpace(param), "=", 2); len(kv) == 2 && strings.TrimSpace(kv[0]) == "q" {
+				if q, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64); err == nil {
+					quality = q
+				}
+			}
+		}
+	}
+	return
+}
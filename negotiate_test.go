@@ -0,0 +1,28 @@
+package rex
+
+import (
+	"net/http"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestNegotiate(t *testing.T) {
+	negotiated := func(accept string, offers ...string) string {
+		request, _ := http.NewRequest("GET", "/", nil)
+		if accept != "" {
+			request.Header.Set("Accept", accept)
+		}
+		return negotiate(request, offers...)
+	}
+
+	Convey("rex.negotiate", t, func() {
+		So(negotiated("", "text/html", "application/json"), ShouldEqual, "text/html")
+		So(negotiated("*/*", "text/html", "application/json"), ShouldEqual, "text/html")
+		So(negotiated("application/json", "text/html", "application/json"), ShouldEqual, "application/json")
+		So(negotiated("text/html;q=0.5, application/json", "text/html", "application/json"), ShouldEqual, "application/json")
+		So(negotiated("application/*;q=0.9, */*;q=0.1", "text/html", "application/json"), ShouldEqual, "application/json")
+		So(negotiated("text/*, text/plain;q=0", "text/plain", "text/html"), ShouldEqual, "text/html")
+		So(negotiated("image/png", "text/html", "application/json"), ShouldBeEmpty)
+	})
+}
@@ -0,0 +1,81 @@
+package rex
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// paramTypes maps the parameter types usable in route patterns, e.g. "/users/{id:int}",
+// to the regular expressions matching them.
+var paramTypes = map[string]string{
+	"int":  `-?[0-9]+`,
+	"uint": `[0-9]+`,
+	"uuid": `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`,
+	"date": `[0-9]{4}-[0-9]{2}-[0-9]{2}`,
+	"slug": `[a-z0-9]+(?:-[a-z0-9]+)*`,
+}
+
+var (
+	paramPattern = regexp.MustCompile(`\{(\w+):(int|uint|uuid|date|slug)\}`)
+	uuidPattern  = regexp.MustCompile("^" + paramTypes["uuid"] + "$")
+
+	errParamMissing = errors.New("missing")
+)
+
+// expand replaces the typed parameters of the pattern with their regular expressions,
+// i.e. "/users/{id:int}" => "/users/{id:-?[0-9]+}", other variables are left intact.
+func expand(pattern string) string {
+	return paramPattern.ReplaceAllStringFunc(pattern, func(param string) string {
+		parts := paramPattern.FindStringSubmatch(param)
+		return "{" + parts[1] + ":" + paramTypes[parts[2]] + "}"
+	})
+}
+
+// paramError reports the invalid route variable as 404 Not Found via the Error hook.
+func paramError(name string, err error) error {
+	return NewError(http.StatusNotFound, fmt.Sprintf("invalid parameter %q", name), err)
+}
+
+// IntParam returns the route variable of the current request as int.
+func IntParam(r *http.Request, name string) (int, error) {
+	value := Param(r, name)
+	if value == "" {
+		return 0, paramError(name, errParamMissing)
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, paramError(name, err)
+	}
+	return number, nil
+}
+
+// UUIDParam returns the route variable of the current request as the canonical (lower-case) UUID.
+func UUIDParam(r *http.Request, name string) (string, error) {
+	value := Param(r, name)
+	if value == "" {
+		return "", paramError(name, errParamMissing)
+	}
+	if !uuidPattern.MatchString(value) {
+		return "", paramError(name, fmt.Errorf("malformed UUID: %s", value))
+	}
+	return strings.ToLower(value), nil
+}
+
+// TimeParam returns the route variable of the current request as time.Time parsed in the layout,
+// e.g. TimeParam(r, "date", "2006-01-02") for "/archives/{date:date}".
+func TimeParam(r *http.Request, name, layout string) (time.Time, error) {
+	value := Param(r, name)
+	if value == "" {
+		return time.Time{}, paramError(name, errParamMissing)
+	}
+	moment, err := time.Parse(layout, value)
+	if err != nil {
+		return time.Time{}, paramError(name, err)
+	}
+	return moment, nil
+}
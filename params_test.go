@@ -0,0 +1,103 @@
+package rex
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestExpand(t *testing.T) {
+	Convey("rex.expand", t, func() {
+		So(expand("/users/{id:int}"), ShouldEqual, "/users/{id:-?[0-9]+}")
+		So(expand("/posts/{slug:slug}/{page:uint}"), ShouldEqual, "/posts/{slug:[a-z0-9]+(?:-[a-z0-9]+)*}/{page:[0-9]+}")
+		So(expand("/users/{id}/{name:[a-z]+}"), ShouldEqual, "/users/{id}/{name:[a-z]+}")
+	})
+}
+
+func TestParams(t *testing.T) {
+	app := New()
+	app.Get("/users/{id:int}", func(w http.ResponseWriter, r *http.Request) error {
+		id, err := IntParam(r, "id")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "user %d", id)
+		return nil
+	})
+	app.Get("/accounts/{id}", func(w http.ResponseWriter, r *http.Request) error {
+		id, err := IntParam(r, "id")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "account %d", id)
+		return nil
+	})
+	app.Get("/orders/{id:uuid}", func(w http.ResponseWriter, r *http.Request) error {
+		id, err := UUIDParam(r, "id")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "order %s", id)
+		return nil
+	})
+	app.Get("/archives/{date:date}", func(w http.ResponseWriter, r *http.Request) error {
+		date, err := TimeParam(r, "date", "2006-01-02")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "archives %s", date.Weekday())
+		return nil
+	})
+	group := app.Group("/teams/{team:uint}")
+	group.Get("/members", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "team %s", Param(r, "team"))
+	})
+
+	serve := func(url string) *httptest.ResponseRecorder {
+		request, _ := http.NewRequest("GET", url, nil)
+		request.Header.Set("Accept", "application/json")
+		response := httptest.NewRecorder()
+		app.ServeHTTP(response, request)
+		return response
+	}
+
+	Convey("rex.IntParam", t, func() {
+		So(serve("/users/42").Body.String(), ShouldEqual, "user 42")
+		So(serve("/users/abc").Code, ShouldEqual, http.StatusNotFound)
+
+		So(serve("/accounts/7").Body.String(), ShouldEqual, "account 7")
+		response := serve("/accounts/abc")
+		So(response.Code, ShouldEqual, http.StatusNotFound)
+		So(response.Body.String(), ShouldEqual, `{"status":404,"message":"invalid parameter \"id\""}`+"\n")
+	})
+
+	Convey("rex.UUIDParam", t, func() {
+		So(serve("/orders/6BA7B810-9DAD-11D1-80B4-00C04FD430C8").Body.String(), ShouldEqual, "order 6ba7b810-9dad-11d1-80b4-00c04fd430c8")
+		So(serve("/orders/6ba7b810").Code, ShouldEqual, http.StatusNotFound)
+	})
+
+	Convey("rex.TimeParam", t, func() {
+		So(serve("/archives/2017-10-01").Body.String(), ShouldEqual, "archives Sunday")
+		So(serve("/archives/2017-13-01").Code, ShouldEqual, http.StatusNotFound)
+		So(serve("/archives/latest").Code, ShouldEqual, http.StatusNotFound)
+	})
+
+	Convey("rex.Group (typed prefix)", t, func() {
+		So(serve("/teams/3/members").Body.String(), ShouldEqual, "team 3")
+		So(serve("/teams/x/members").Code, ShouldEqual, http.StatusNotFound)
+	})
+
+	Convey("rex.IntParam (missing)", t, func() {
+		request, _ := http.NewRequest("GET", "/", nil)
+		_, err := IntParam(request, "id")
+		So(err, ShouldNotBeNil)
+		_, err = UUIDParam(request, "id")
+		So(err, ShouldNotBeNil)
+		_, err = TimeParam(request, "date", time.RFC3339)
+		So(err.(*HTTPError).Status, ShouldEqual, http.StatusNotFound)
+	})
+}
@@ -0,0 +1,244 @@
+package rex
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/goanywhere/rex/internal"
+)
+
+// Templates executes the named HTML templates, e.g. *html/template.Template.
+type Templates interface {
+	ExecuteTemplate(w io.Writer, name string, data interface{}) error
+}
+
+// RequestTemplates executes the named HTML templates along with the request,
+// e.g. for the request-scoped helpers like XSRF token of view.Engine.
+type RequestTemplates interface {
+	Templates
+	ExecuteRequest(w io.Writer, r *http.Request, name string, data interface{}) error
+}
+
+// View is the named HTML template along with its data, rendered as HTML for browsers,
+// while the data alone is rendered for the other negotiated content types.
+type View struct {
+	Name string
+	Data interface{}
+}
+
+var (
+	callbackPattern = regexp.MustCompile(`^[a-zA-Z_$][0-9a-zA-Z_$.]*$`)
+
+	errNoTemplates = errors.New("rex: no templates to render")
+)
+
+// Templates sets the HTML templates rendered by Render/HTML. Subservers (groups/hosts)
+// inherit the templates unless they set their own.
+func (self *server) Templates(templates Templates) {
+	self.templates = templates
+}
+
+// views returns the templates of the server or its closest ancestor.
+func (self *server) views() Templates {
+	for server := self; server != nil; server = server.parent {
+		if server.templates != nil {
+			return server.templates
+		}
+	}
+	return nil
+}
+
+// Render responds v with the status code in the content type negotiated from the Accept header:
+// HTML (View only), JSON, XML, MessagePack or plain text (string, []byte, error & fmt.Stringer only).
+// HTTPError with 406 Not Acceptable is returned if none is acceptable, nothing is written then.
+func Render(w http.ResponseWriter, r *http.Request, status int, v interface{}) error {
+	var offers []string
+	var data = v
+	view, isView := v.(View)
+	if isView {
+		offers = append(offers, "text/html")
+		data = view.Data
+	}
+	offers = append(offers, "application/json", "application/xml", "text/xml", "application/msgpack", "application/x-msgpack")
+	if _, ok := text(data); ok {
+		offers = append(offers, "text/plain")
+	}
+
+	w.Header().Add("Vary", "Accept")
+	switch negotiate(r, offers...) {
+	case "text/html":
+		return HTML(w, r, status, view.Name, view.Data)
+	case "application/json":
+		return JSON(w, r, status, data)
+	case "application/xml", "text/xml":
+		return XML(w, r, status, data)
+	case "application/msgpack", "application/x-msgpack":
+		return MsgPack(w, r, status, data)
+	case "text/plain":
+		return Text(w, r, status, data)
+	}
+	return NewError(http.StatusNotAcceptable, "", fmt.Errorf("none of %v accepted", offers))
+}
+
+// JSON responds v in JSON with the status code, indented in debug mode.
+func JSON(w http.ResponseWriter, r *http.Request, status int, v interface{}) error {
+	data, err := encodeJSON(r, v)
+	if err != nil {
+		return err
+	}
+	return write(w, r, status, "application/json; charset=utf-8", data)
+}
+
+// JSONP responds v in JSONP with the status code if the "callback" query parameter is given,
+// in JSON otherwise. JSONP is readable cross-origin, thus never use it for private data.
+func JSONP(w http.ResponseWriter, r *http.Request, status int, v interface{}) error {
+	callback := r.URL.Query().Get("callback")
+	if callback == "" {
+		return JSON(w, r, status, v)
+	}
+	if !callbackPattern.MatchString(callback) {
+		return NewError(http.StatusBadRequest, "invalid JSONP callback", nil)
+	}
+	data, err := encodeJSON(r, v)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	return write(w, r, status, "application/javascript; charset=utf-8",
+		[]byte(fmt.Sprintf("/**/ typeof %s === 'function' && %s(%s);", callback, callback, bytes.TrimSpace(data))))
+}
+
+// encodeJSON returns the JSON encoding of v, indented in debug mode.
+func encodeJSON(r *http.Request, v interface{}) ([]byte, error) {
+	var buffer = new(bytes.Buffer)
+	encoder := json.NewEncoder(buffer)
+	if ctx := internal.FromRequest(r); ctx != nil && ctx.Debug {
+		encoder.SetIndent("", "  ")
+	}
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// XML responds v in XML with the status code.
+func XML(w http.ResponseWriter, r *http.Request, status int, v interface{}) error {
+	var buffer = bytes.NewBufferString(xml.Header)
+	if err := xml.NewEncoder(buffer).Encode(v); err != nil {
+		return err
+	}
+	return write(w, r, status, "application/xml; charset=utf-8", buffer.Bytes())
+}
+
+// MsgPack responds v in MessagePack with the status code.
+func MsgPack(w http.ResponseWriter, r *http.Request, status int, v interface{}) error {
+	data, err := encodeMsgpack(v)
+	if err != nil {
+		return err
+	}
+	return write(w, r, status, "application/msgpack", data)
+}
+
+// Text responds v (string, []byte, error or fmt.Stringer) in plain text with the status code.
+func Text(w http.ResponseWriter, r *http.Request, status int, v interface{}) error {
+	data, ok := text(v)
+	if !ok {
+		return fmt.Errorf("rex: unsupported text: %T", v)
+	}
+	return write(w, r, status, "text/plain; charset=utf-8", data)
+}
+
+// HTML responds the named template (set via Templates) executed with data with the status code.
+func HTML(w http.ResponseWriter, r *http.Request, status int, name string, data interface{}) error {
+	ctx := internal.FromRequest(r)
+	if ctx == nil || ctx.Templates == nil {
+		return errNoTemplates
+	}
+	var buffer = new(bytes.Buffer)
+	var err error
+	if templates, ok := ctx.Templates.(RequestTemplates); ok {
+		err = templates.ExecuteRequest(buffer, r, name, data)
+	} else {
+		err = ctx.Templates.ExecuteTemplate(buffer, name, data)
+	}
+	if err != nil {
+		return err
+	}
+	return write(w, r, status, "text/html; charset=utf-8", buffer.Bytes())
+}
+
+// text returns the plain text of the value, false if unsupported.
+func text(v interface{}) ([]byte, bool) {
+	switch value := v.(type) {
+	case string:
+		return []byte(value), true
+	case []byte:
+		return value, true
+	case error:
+		return []byte(value.Error()), true
+	case fmt.Stringer:
+		return []byte(value.String()), true
+	}
+	return nil, false
+}
+
+// write responds the encoded body with the status code, content type & length,
+// 200 OK responses are tagged with the weak ETag (if enabled) to answer 304 Not Modified.
+func write(w http.ResponseWriter, r *http.Request, status int, contentType string, body []byte) error {
+	var header = w.Header()
+	if ctx := internal.FromRequest(r); ctx != nil && ctx.ETag && status == http.StatusOK {
+		hash := fnv.New64a()
+		hash.Write(body)
+		etag := fmt.Sprintf(`W/"%x-%x"`, len(body), hash.Sum64())
+		header.Set("ETag", etag)
+		if (r.Method == "GET" || r.Method == "HEAD") && matchETag(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return nil
+		}
+	}
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(status)
+	_, err := w.Write(body)
+	return err
+}
+
+// matchETag reports whether the If-None-Match header matches the ETag (weak comparison).
+func matchETag(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// MarshalXML encodes the map as XML elements named after its (sorted) keys.
+func (self M) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	var keys []string
+	for key := range self {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := e.EncodeElement(self[key], xml.StartElement{Name: xml.Name{Local: key}}); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
@@ -0,0 +1,176 @@
+package rex
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRender(t *testing.T) {
+	type User struct {
+		Name string `json:"name" xml:"name"`
+	}
+
+	config := NewConfig()
+	config.Debug = false
+	app := NewWithConfig(config)
+	app.Templates(template.Must(template.New("user.html").Parse("<p>{{.Name}}</p>")))
+	app.Get("/users", func(w http.ResponseWriter, r *http.Request) error {
+		return Render(w, r, http.StatusCreated, View{"user.html", User{"rex"}})
+	})
+	app.Get("/data", func(w http.ResponseWriter, r *http.Request) error {
+		return Render(w, r, http.StatusOK, M{"name": "rex"})
+	})
+	app.Get("/jsonp", func(w http.ResponseWriter, r *http.Request) error {
+		return JSONP(w, r, http.StatusOK, M{"name": "rex"})
+	})
+	app.Get("/text", func(w http.ResponseWriter, r *http.Request) error {
+		return Render(w, r, http.StatusOK, errors.New("rex"))
+	})
+	app.Get("/missing", func(w http.ResponseWriter, r *http.Request) error {
+		return Render(w, r, http.StatusOK, View{"missing.html", nil})
+	})
+
+	serve := func(app http.Handler, url, accept string) *httptest.ResponseRecorder {
+		request, _ := http.NewRequest("GET", url, nil)
+		if accept != "" {
+			request.Header.Set("Accept", accept)
+		}
+		response := httptest.NewRecorder()
+		app.ServeHTTP(response, request)
+		return response
+	}
+
+	Convey("rex.Render (HTML)", t, func() {
+		response := serve(app, "/users", "text/html,*/*;q=0.8")
+		So(response.Code, ShouldEqual, http.StatusCreated)
+		So(response.Header().Get("Content-Type"), ShouldEqual, "text/html; charset=utf-8")
+		So(response.Header().Get("Vary"), ShouldEqual, "Accept")
+		So(response.Body.String(), ShouldEqual, "<p>rex</p>")
+
+		So(serve(app, "/missing", "text/html").Code, ShouldEqual, http.StatusInternalServerError)
+	})
+
+	Convey("rex.Render (JSON)", t, func() {
+		response := serve(app, "/users", "application/json")
+		So(response.Code, ShouldEqual, http.StatusCreated)
+		So(response.Header().Get("Content-Type"), ShouldEqual, "application/json; charset=utf-8")
+		So(response.Body.String(), ShouldEqual, `{"name":"rex"}`+"\n")
+
+		// JSON is preferred for non-HTML data.
+		So(serve(app, "/data", "*/*").Body.String(), ShouldEqual, `{"name":"rex"}`+"\n")
+		So(serve(app, "/data", "").Body.String(), ShouldEqual, `{"name":"rex"}`+"\n")
+	})
+
+	Convey("rex.JSONP", t, func() {
+		response := serve(app, "/jsonp?callback=jQuery.cb_1", "")
+		So(response.Header().Get("Content-Type"), ShouldEqual, "application/javascript; charset=utf-8")
+		So(response.Body.String(), ShouldEqual, `/**/ typeof jQuery.cb_1 === 'function' && jQuery.cb_1({"name":"rex"});`)
+
+		So(serve(app, "/jsonp?callback=alert(1)", "").Code, ShouldEqual, http.StatusBadRequest)
+		So(serve(app, "/jsonp", "").Header().Get("Content-Type"), ShouldEqual, "application/json; charset=utf-8")
+	})
+
+	Convey("rex.Render (callback ignored)", t, func() {
+		response := serve(app, "/data?callback=steal", "")
+		So(response.Header().Get("Content-Type"), ShouldEqual, "application/json; charset=utf-8")
+		So(response.Body.String(), ShouldEqual, `{"name":"rex"}`+"\n")
+	})
+
+	Convey("rex.Render (JSON in debug mode)", t, func() {
+		app := New()
+		app.Get("/", func(w http.ResponseWriter, r *http.Request) error {
+			return JSON(w, r, http.StatusOK, M{"name": "rex"})
+		})
+		So(serve(app, "/", "").Body.String(), ShouldEqual, "{\n  \"name\": \"rex\"\n}\n")
+	})
+
+	Convey("rex.Render (XML)", t, func() {
+		response := serve(app, "/users", "application/xml")
+		So(response.Header().Get("Content-Type"), ShouldEqual, "application/xml; charset=utf-8")
+		So(response.Body.String(), ShouldEqual, `<?xml version="1.0" encoding="UTF-8"?>`+"\n<User><name>rex</name></User>")
+
+		So(serve(app, "/data", "text/xml").Body.String(), ShouldEqual, `<?xml version="1.0" encoding="UTF-8"?>`+"\n<M><name>rex</name></M>")
+	})
+
+	Convey("rex.Render (MessagePack)", t, func() {
+		response := serve(app, "/data", "application/x-msgpack")
+		So(response.Header().Get("Content-Type"), ShouldEqual, "application/msgpack")
+		So(response.Body.Bytes(), ShouldResemble, []byte{0x81, 0xa4, 'n', 'a', 'm', 'e', 0xa3, 'r', 'e', 'x'})
+	})
+
+	Convey("rex.Render (plain text)", t, func() {
+		response := serve(app, "/text", "text/plain")
+		So(response.Header().Get("Content-Type"), ShouldEqual, "text/plain; charset=utf-8")
+		So(response.Body.String(), ShouldEqual, "rex")
+	})
+
+	Convey("rex.Render (406)", t, func() {
+		So(serve(app, "/data", "text/plain").Code, ShouldEqual, http.StatusNotAcceptable)
+		So(serve(app, "/data", "text/html").Code, ShouldEqual, http.StatusNotAcceptable)
+		So(serve(app, "/users", "image/png").Code, ShouldEqual, http.StatusNotAcceptable)
+	})
+}
+
+func TestWrite(t *testing.T) {
+	app := New()
+	app.Get("/", func(w http.ResponseWriter, r *http.Request) error {
+		return Text(w, r, http.StatusOK, "rex")
+	})
+	app.Post("/", func(w http.ResponseWriter, r *http.Request) error {
+		return Text(w, r, http.StatusCreated, "rex")
+	})
+
+	serve := func(app http.Handler, method, etag string) *httptest.ResponseRecorder {
+		request, _ := http.NewRequest(method, "/", nil)
+		if etag != "" {
+			request.Header.Set("If-None-Match", etag)
+		}
+		response := httptest.NewRecorder()
+		app.ServeHTTP(response, request)
+		return response
+	}
+
+	Convey("rex.write (Content-Type & Content-Length)", t, func() {
+		response := serve(app, "GET", "")
+		So(response.Code, ShouldEqual, http.StatusOK)
+		So(response.Header().Get("Content-Type"), ShouldEqual, "text/plain; charset=utf-8")
+		So(response.Header().Get("Content-Length"), ShouldEqual, "3")
+	})
+
+	Convey("rex.write (ETag)", t, func() {
+		etag := serve(app, "GET", "").Header().Get("ETag")
+		So(etag, ShouldStartWith, `W/"3-`)
+
+		response := serve(app, "GET", etag)
+		So(response.Code, ShouldEqual, http.StatusNotModified)
+		So(response.Body.Len(), ShouldEqual, 0)
+		So(response.Header().Get("Content-Length"), ShouldBeEmpty)
+
+		So(serve(app, "GET", `"other", `+strings.TrimPrefix(etag, "W/")).Code, ShouldEqual, http.StatusNotModified)
+		So(serve(app, "GET", "*").Code, ShouldEqual, http.StatusNotModified)
+		So(serve(app, "GET", `W/"other"`).Code, ShouldEqual, http.StatusOK)
+
+		// only 200 OK responses are tagged.
+		response = serve(app, "POST", etag)
+		So(response.Code, ShouldEqual, http.StatusCreated)
+		So(response.Header().Get("ETag"), ShouldBeEmpty)
+	})
+
+	Convey("rex.write (ETag disabled)", t, func() {
+		config := NewConfig()
+		config.ETag = false
+		app := NewWithConfig(config)
+		app.Get("/", func(w http.ResponseWriter, r *http.Request) error {
+			return Text(w, r, http.StatusOK, "rex")
+		})
+		response := serve(app, "GET", "*")
+		So(response.Code, ShouldEqual, http.StatusOK)
+		So(response.Header().Get("ETag"), ShouldBeEmpty)
+	})
+}
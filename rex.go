@@ -13,7 +13,7 @@ import (
 // Shortcut for string based map.
 type M map[string]interface{}
 
-// Sends the HTTP response in JSON.
+// Sends the HTTP response in JSON, see Render for the negotiated responses.
 func Send(w http.ResponseWriter, v interface{}) {
 	var buffer = new(bytes.Buffer)
 	defer func() {
@@ -28,10 +28,6 @@ func Send(w http.ResponseWriter, v interface{}) {
 	}
 }
 
-// Renders a view (Pongo) and sends the rendered HTML string to the client.
-// Optional parameters: value, local variables for the view.
-// func Render(filename string, v ...interface{}) {}
-
 func init() {
 	var basedir = fs.Getcd(2)
 	env.Set("basedir", basedir)
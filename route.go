@@ -0,0 +1,83 @@
+package rex
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/goanywhere/rex/internal"
+	"github.com/gorilla/mux"
+)
+
+// Route represents a registered route, which wraps the Gorilla mux.Route
+// to add further matchers & route-level middleware modules.
+type Route struct {
+	server     *server
+	name       string
+	named      bool // custom name given, forwarded to the mux.Route.
+	methods    []string
+	pattern    string // path pattern as given, typed variables unexpanded.
+	route      *mux.Route
+	middleware *middleware
+}
+
+// GetName returns the name of the route, defaults to "METHODS:/pattern".
+func (self *Route) GetName() string {
+	return self.name
+}
+
+// Name sets a custom name for the route, which must be unique across the application
+// & can be given only once, as mux.Route does.
+func (self *Route) Name(name string) *Route {
+	if self.named {
+		panic(fmt.Sprintf("rex: route %q already named, can't rename to %q", self.name, name))
+	}
+	if route := self.server.root().lookup(name); route != nil && route != self {
+		panic(fmt.Sprintf("rex: route name %q already in use", name))
+	}
+	self.name, self.named = name, true
+	self.route.Name(name)
+	return self
+}
+
+// Headers adds a matcher for request header values, e.g.
+//
+//	app.Get("/", index).Headers("Content-Type", "application/json")
+//
+// See mux.Route.Headers for further details.
+func (self *Route) Headers(pairs ...string) *Route {
+	self.route.Headers(pairs...)
+	return self
+}
+
+// Queries adds a matcher for URL query values, e.g.
+//
+//	app.Get("/", index).Queries("page", "{page:[0-9]+}")
+//
+// See mux.Route.Queries for further details.
+func (self *Route) Queries(pairs ...string) *Route {
+	self.route.Queries(pairs...)
+	return self
+}
+
+// Schemes adds a matcher for URL schemes, e.g.
+//
+//	app.Get("/", index).Schemes("https")
+func (self *Route) Schemes(schemes ...string) *Route {
+	self.route.Schemes(schemes...)
+	return self
+}
+
+// Use adds the middleware modules to wrap the handler of this route only,
+// which run after the middleware stack of the server/group.
+func (self *Route) Use(modules ...func(http.Handler) http.Handler) *Route {
+	self.middleware.stack = append(self.middleware.stack, modules...)
+	return self
+}
+
+// ServeHTTP dispatches the request to the route-level middleware modules & handler.
+func (self *Route) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if ctx := internal.FromRequest(r); ctx != nil {
+		ctx.Route, ctx.Vars = self.name, mux.Vars(r)
+	}
+	self.middleware.ServeHTTP(w, r)
+}
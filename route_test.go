@@ -0,0 +1,109 @@
+package rex
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRoute(t *testing.T) {
+	app := New()
+	// more specific route goes first.
+	app.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "json")
+	}).Name("api").Headers("Accept", "application/json").Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			next.ServeHTTP(w, r)
+		})
+	})
+	index := app.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "index")
+	})
+	app.Get("/users", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "page "+app.Vars(r)["page"])
+	}).Queries("page", "{page:[0-9]+}")
+	app.Get("/secure", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "secure")
+	}).Schemes("https")
+
+	Convey("rex.Route", t, func() {
+		So(index.GetName(), ShouldEqual, "GET:/")
+
+		request, _ := http.NewRequest("GET", "/", nil)
+		response := httptest.NewRecorder()
+		app.ServeHTTP(response, request)
+		So(response.Body.String(), ShouldEqual, "index")
+		So(app.Name(request), ShouldEqual, "GET:/")
+
+		request.Header.Set("Accept", "application/json")
+		response = httptest.NewRecorder()
+		app.ServeHTTP(response, request)
+		So(response.Body.String(), ShouldEqual, "json")
+		So(response.Header().Get("Content-Type"), ShouldEqual, "application/json")
+		So(app.Name(request), ShouldEqual, "api")
+
+		request, _ = http.NewRequest("GET", "/users?page=2", nil)
+		response = httptest.NewRecorder()
+		app.ServeHTTP(response, request)
+		So(response.Body.String(), ShouldEqual, "page 2")
+
+		request, _ = http.NewRequest("GET", "/users?page=last", nil)
+		response = httptest.NewRecorder()
+		app.ServeHTTP(response, request)
+		So(response.Code, ShouldEqual, http.StatusNotFound)
+
+		request, _ = http.NewRequest("GET", "http://localhost/secure", nil)
+		response = httptest.NewRecorder()
+		app.ServeHTTP(response, request)
+		So(response.Code, ShouldEqual, http.StatusNotFound)
+
+		request, _ = http.NewRequest("GET", "https://localhost/secure", nil)
+		response = httptest.NewRecorder()
+		app.ServeHTTP(response, request)
+		So(response.Body.String(), ShouldEqual, "secure")
+	})
+
+	Convey("rex.Route (group)", t, func() {
+		user := app.Group("/accounts")
+		user.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {}).Name("account")
+
+		request, _ := http.NewRequest("GET", "/accounts/1", nil)
+		So(app.Name(request), ShouldEqual, "account")
+	})
+}
+
+func TestRouteName(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, mux.CurrentRoute(r).GetName())
+	}
+	app := New()
+	app.Get("/", handler).Name("index")
+	app.Get("/login", handler)
+	app.Group("/api").Get("/", handler).Name("api")
+
+	serve := func(path string) string {
+		response := httptest.NewRecorder()
+		app.ServeHTTP(response, httptest.NewRequest("GET", path, nil))
+		return response.Body.String()
+	}
+
+	Convey("rex.Route.Name (mux.Route)", t, func() {
+		So(serve("/"), ShouldEqual, "index")
+		So(serve("/login"), ShouldEqual, "GET:/login")
+		So(serve("/api/"), ShouldEqual, "api")
+		So(app.mux.Get("api"), ShouldNotBeNil)
+	})
+
+	Convey("rex.Route.Name (duplicated)", t, func() {
+		app := New()
+		route := app.Get("/", handler).Name("index")
+		So(func() { app.Group("/api").Get("/", handler).Name("index") }, ShouldPanic)
+		So(func() { app.Get("/about", handler).Name("GET:/") }, ShouldPanic)
+		So(func() { route.Name("home") }, ShouldPanic)
+	})
+}
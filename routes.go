@@ -0,0 +1,96 @@
+package rex
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"reflect"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+)
+
+// RouteInfo describes a registered route of the application.
+type RouteInfo struct {
+	Name       string   `json:"name"`
+	Methods    []string `json:"methods"`
+	Pattern    string   `json:"pattern"`
+	Host       string   `json:"host,omitempty"`
+	Prefix     string   `json:"prefix,omitempty"`
+	Middleware []string `json:"middleware,omitempty"`
+}
+
+// Routes returns the registered routes of the server & its subservers (groups/hosts),
+// in the order of registration, along with the middleware modules wrapping each route,
+// i.e. server => group(s) => route-level ones.
+func (self *server) Routes() (routes []RouteInfo) {
+	var modules []string
+	for server := self; server != nil; server = server.parent {
+		modules = append(server.modules(), modules...)
+	}
+	return self.walk(modules)
+}
+
+// walk collects the routes recursively with the middleware modules of the ancestors.
+func (self *server) walk(ancestors []string) (routes []RouteInfo) {
+	for _, route := range self.routes {
+		info := RouteInfo{
+			Name:    route.name,
+			Methods: route.methods,
+			Host:    self.host,
+			Pattern: self.prefix + route.pattern,
+			Prefix:  self.prefix,
+		}
+		info.Middleware = append(info.Middleware, ancestors...)
+		for _, module := range route.middleware.stack {
+			info.Middleware = append(info.Middleware, funcName(module))
+		}
+		routes = append(routes, info)
+	}
+	for _, server := range self.subservers {
+		routes = append(routes, server.walk(append(ancestors[:len(ancestors):len(ancestors)], server.modules()...))...)
+	}
+	return
+}
+
+// modules returns the names of the middleware modules used by the server itself.
+func (self *server) modules() (names []string) {
+	var stack = self.middleware.stack
+	if self.ready && len(stack) > 0 {
+		// skip the final module (mux) added by build.
+		stack = stack[:len(stack)-1]
+	}
+	for _, module := range stack {
+		names = append(names, funcName(module))
+	}
+	return
+}
+
+// printRoutes writes the route table in the given format ("json" or "text").
+func (self *server) printRoutes(w io.Writer, format string) error {
+	var routes = self.Routes()
+	if format == "json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(routes)
+	}
+
+	writer := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "METHODS\tPATTERN\tNAME\tHOST\tMIDDLEWARE")
+	for _, route := range routes {
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\n",
+			strings.Join(route.Methods, "|"), route.Pattern, route.Name, route.Host, strings.Join(route.Middleware, ", "))
+	}
+	return writer.Flush()
+}
+
+// funcName returns the short function name (with package name) of the given function.
+func funcName(fn interface{}) string {
+	if pc := reflect.ValueOf(fn).Pointer(); pc != 0 {
+		if f := runtime.FuncForPC(pc); f != nil {
+			return path.Base(f.Name())
+		}
+	}
+	return "unknown"
+}
@@ -0,0 +1,89 @@
+package rex
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func logging(next http.Handler) http.Handler { return next }
+
+func auth(next http.Handler) http.Handler { return next }
+
+func TestRoutes(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Powered-By", "rex")
+	}
+
+	app := New()
+	app.Use(logging)
+	app.Get("/", handler).Name("index")
+
+	api := app.Group("/v1")
+	api.Post("/users", handler, auth)
+
+	admin := api.Group("/admin")
+	admin.Use(auth)
+	admin.Delete("/users/{id}", handler)
+
+	blog := app.Host("blog.example.com")
+	blog.Get("/", handler)
+
+	accounts := app.Group("/accounts/{account:int}")
+	accounts.Get("/orders/{id:uuid}", handler)
+
+	Convey("rex.Routes", t, func() {
+		routes := app.Routes()
+		So(len(routes), ShouldEqual, 5)
+
+		So(routes[0], ShouldResemble, RouteInfo{
+			Name:       "index",
+			Methods:    []string{"GET"},
+			Pattern:    "/",
+			Middleware: []string{"rex.logging"},
+		})
+		So(routes[1], ShouldResemble, RouteInfo{
+			Name:       "POST:/users",
+			Methods:    []string{"POST"},
+			Pattern:    "/v1/users",
+			Prefix:     "/v1",
+			Middleware: []string{"rex.logging", "rex.auth"},
+		})
+		So(routes[2].Pattern, ShouldEqual, "/v1/admin/users/{id}")
+		So(routes[2].Prefix, ShouldEqual, "/v1/admin")
+		So(routes[2].Middleware, ShouldResemble, []string{"rex.logging", "rex.auth"})
+		So(routes[3].Host, ShouldEqual, "blog.example.com")
+		// typed variables reported as given.
+		So(routes[4].Pattern, ShouldEqual, "/accounts/{account:int}/orders/{id:uuid}")
+		So(routes[4].Prefix, ShouldEqual, "/accounts/{account:int}")
+
+		// subserver only, built servers should not report the mux.
+		request, _ := http.NewRequest("DELETE", "/v1/admin/users/1", nil)
+		response := httptest.NewRecorder()
+		app.ServeHTTP(response, request)
+		So(response.Header().Get("X-Powered-By"), ShouldEqual, "rex")
+
+		routes = admin.Routes()
+		So(len(routes), ShouldEqual, 1)
+		So(routes[0].Middleware, ShouldResemble, []string{"rex.logging", "rex.auth"})
+	})
+
+	Convey("rex.printRoutes", t, func() {
+		var buffer = new(bytes.Buffer)
+		So(app.printRoutes(buffer, "json"), ShouldBeNil)
+
+		var routes []RouteInfo
+		So(json.Unmarshal(buffer.Bytes(), &routes), ShouldBeNil)
+		So(routes, ShouldResemble, app.Routes())
+
+		buffer.Reset()
+		So(app.printRoutes(buffer, "text"), ShouldBeNil)
+		So(buffer.String(), ShouldStartWith, "METHODS")
+		So(buffer.String(), ShouldContainSubstring, "/v1/admin/users/{id}")
+		So(buffer.String(), ShouldNotContainSubstring, "[0-9]")
+	})
+}
@@ -1,51 +1,65 @@
 package rex
 
 import (
-	"flag"
-	"fmt"
+	"context"
+	"crypto/tls"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"path"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
-	"time"
+	"syscall"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/goanywhere/env"
+	"github.com/goanywhere/rex/internal"
 	"github.com/gorilla/mux"
 )
 
-var (
-	debug    bool
-	port     int
-	maxprocs int
-
-	once sync.Once
-)
-
 type server struct {
+	parent     *server
+	prefix     string // path prefix of the group.
+	host       string // (sub)domain of the group.
+	config     *Config
 	middleware *middleware
 	mux        *mux.Router
 	ready      bool
+	routes     []*Route
 	subservers []*server
+
+	notFound         http.Handler
+	methodNotAllowed http.Handler
+	onError          ErrorFunc
+	templates        Templates
+
+	http     *http.Server
+	redirect *http.Server
+	listener *listener
+	sockets  []net.Listener // raw listeners to hand off on upgrade.
+	hooks    []func()
+	mutex    sync.Mutex // guards the servers & listeners above, set once serving.
+
+	shutdown    sync.Once
+	shutdownErr error
 }
 
+// New creates an application server with settings from env.
 func New() *server {
-	self := &server{
+	return NewWithConfig(NewConfig())
+}
+
+// NewWithConfig creates an application server with the given settings.
+func NewWithConfig(config *Config) *server {
+	return &server{
+		config:     config,
 		middleware: new(middleware),
 		mux:        mux.NewRouter().StrictSlash(true),
 	}
-	self.configure()
-	return self
-}
-
-func (self *server) configure() {
-	once.Do(func() {
-		flag.BoolVar(&debug, "debug", env.Bool("DEBUG", true), "flag to toggle debug mode")
-		flag.IntVar(&port, "port", env.Int("PORT", 5000), "port to run the application server")
-		flag.IntVar(&maxprocs, "maxprocs", env.Int("MAXPROCS", runtime.NumCPU()), "maximum cpu processes to run the server")
-		flag.Parse()
-	})
 }
 
 // build constructs all server/subservers along with their middleware modules chain.
@@ -53,51 +67,155 @@ func (self *server) build() http.Handler {
 	if !self.ready {
 		// * add server mux into middlware stack to serve as final http.Handler.
 		self.Use(func(http.Handler) http.Handler {
-			return self.mux
+			return http.HandlerFunc(self.dispatch)
 		})
-		// * add subservers into middlware stack to serve as final http.Handler.
-		for index := 0; index < len(self.subservers); index++ {
-			server := self.subservers[index]
-			server.Use(func(http.Handler) http.Handler {
-				return server.mux
-			})
+		// * name the mux routes without custom names, unless taken by the earlier ones.
+		for _, route := range self.routes {
+			if !route.named && self.mux.Get(route.name) == nil {
+				route.route.Name(route.name)
+			}
+		}
+		// * add subservers (recursively) into middlware stack to serve as final http.Handler.
+		for _, server := range self.subservers {
+			server.build()
 		}
 		self.ready = true
 	}
 	return self.middleware
 }
 
-// register adds the http.Handler/http.HandleFunc into Gorilla mux.
-func (self *server) register(pattern string, handler interface{}, methods ...string) {
-	var name = strings.Join(methods, "|") + ":" + pattern
+// dispatch serves the request via the server mux, responds 405 Method Not Allowed
+// (or the allowed methods for OPTIONS) if the URL matched with other methods only,
+// 404 Not Found if nothing matched at all.
+func (self *server) dispatch(w http.ResponseWriter, r *http.Request) {
+	if ctx := internal.FromRequest(r); ctx != nil {
+		ctx.Error, ctx.Templates = self.error, self.views()
+	}
+	var match mux.RouteMatch
+	if self.mux.Match(r, &match) || !clean(r.URL.Path) {
+		// unclean paths are redirected by mux.
+		self.mux.ServeHTTP(w, r)
+	} else if methods := self.allowed(r); len(methods) > 0 {
+		w.Header().Set("Allow", strings.Join(methods, ", "))
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			self.methodNotAllowedHandler().ServeHTTP(w, r)
+		}
+	} else {
+		self.notFoundHandler().ServeHTTP(w, r)
+	}
+}
+
+// resolve returns the name & variables of the route (including subservers) matching the request.
+func (self *server) resolve(r *http.Request) (name string, vars map[string]string) {
+	if route, vars := self.match(r); route != nil {
+		return route.name, vars
+	}
+	return "", nil
+}
+
+// clean reports whether the URL path is in its canonical form.
+func clean(p string) bool {
+	if p == "" || p == "/" {
+		return true
+	}
+	cleaned := path.Clean(p)
+	if strings.HasSuffix(p, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	return cleaned == p
+}
+
+// allowed returns the methods registered for the request URL, OPTIONS included.
+func (self *server) allowed(r *http.Request) (methods []string) {
+	var found = make(map[string]bool)
+	for _, route := range self.routes {
+		for _, method := range route.methods {
+			if found[method] {
+				continue
+			}
+			var match mux.RouteMatch
+			request := *r
+			request.Method = method
+			if route.route.Match(&request, &match) {
+				found[method] = true
+				methods = append(methods, method)
+			}
+		}
+	}
+	if len(methods) > 0 && !found["OPTIONS"] {
+		methods = append(methods, "OPTIONS")
+	}
+	return
+}
+
+// register adds the http.Handler/http.HandleFunc (or the handler returning error) into Gorilla mux.
+func (self *server) register(pattern string, handler interface{}, methods ...string) *Route {
+	var route = &Route{server: self, name: strings.Join(methods, "|") + ":" + pattern, methods: methods, pattern: pattern, middleware: new(middleware)}
 	// finds the full function name (with package) as its mappings.
 	//var name = runtime.FuncForPC(reflect.ValueOf(handler).Pointer()).Name()
 
+	if route.middleware.handler = self.handlerOf(handler); route.middleware.handler == nil {
+		panic("Unsupported handler: " + route.name)
+	}
+	route.route = self.mux.Handle(expand(pattern), route).Methods(methods...)
+	self.routes = append(self.routes, route)
+	return route
+}
+
+// handlerOf converts the http.Handler/http.HandleFunc (or the handler returning error,
+// responded via the Error hook) into http.Handler, nil if unsupported.
+func (self *server) handlerOf(handler interface{}) http.Handler {
 	switch H := handler.(type) {
 	case http.Handler:
-		self.mux.Handle(pattern, H).Methods(methods...).Name(name)
+		return H
 
 	case func(http.ResponseWriter, *http.Request):
-		self.mux.HandleFunc(pattern, H).Methods(methods...).Name(name)
+		return http.HandlerFunc(H)
+
+	case func(http.ResponseWriter, *http.Request) error:
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := H(w, r); err != nil {
+				self.fail(w, r, err)
+			}
+		})
+	}
+	return nil
+}
 
-	default:
-		panic("Unsupported handler: " + name)
+// match finds the registered route (including subservers) for the given request, along with its variables.
+func (self *server) match(r *http.Request) (*Route, map[string]string) {
+	var match mux.RouteMatch
+	if self.mux.Match(r, &match) {
+		for _, route := range self.routes {
+			if route.route == match.Route {
+				return route, match.Vars
+			}
+		}
+	}
+	for _, server := range self.subservers {
+		if route, vars := server.match(r); route != nil {
+			return route, vars
+		}
 	}
+	return nil, nil
 }
 
 // Any maps most common HTTP methods request to the given `http.Handler`.
 // Supports: GET | POST | PUT | DELETE | OPTIONS | HEAD
-func (self *server) Any(pattern string, handler interface{}) {
-	self.register(pattern, handler, "GET", "POST", "PUT", "DELETE", "OPTIONS", "HEAD")
+func (self *server) Any(pattern string, handler interface{}, modules ...func(http.Handler) http.Handler) *Route {
+	return self.register(pattern, handler, "GET", "POST", "PUT", "DELETE", "OPTIONS", "HEAD").Use(modules...)
 }
 
 // Group creates a new application group under the given path prefix.
 func (self *server) Group(prefix string) *server {
 	var middleware = new(middleware)
-	self.mux.PathPrefix(prefix).Handler(middleware)
-	var mux = self.mux.PathPrefix(prefix).Subrouter()
+	// typed variables are expanded for the mux only, the given prefix is kept for introspection.
+	self.mux.PathPrefix(expand(prefix)).Handler(middleware)
+	var mux = self.mux.PathPrefix(expand(prefix)).Subrouter()
 
-	server := &server{middleware: middleware, mux: mux}
+	server := &server{parent: self, prefix: self.prefix + prefix, host: self.host, config: self.config, middleware: middleware, mux: mux}
 	self.subservers = append(self.subservers, server)
 	return server
 }
@@ -105,19 +223,18 @@ func (self *server) Group(prefix string) *server {
 // Host creates a new application group under the given (sub)domain.
 func (self *server) Host(domain string) *server {
 	var middleware = new(middleware)
-  self.mux.Host(domain).Handler(middleware)
-  var mux = self.mux.Host(domain).Subrouter()
+	self.mux.Host(domain).Handler(middleware)
+	var mux = self.mux.Host(domain).Subrouter()
 
-	server := &server{middleware: middleware, mux: mux}
+	server := &server{parent: self, prefix: self.prefix, host: domain, config: self.config, middleware: middleware, mux: mux}
 	self.subservers = append(self.subservers, server)
 	return server
 }
 
 // Name returns route name for the given request, if any.
 func (self *server) Name(r *http.Request) (name string) {
-	var match mux.RouteMatch
-	if self.mux.Match(r, &match) {
-		name = match.Route.GetName()
+	if route, _ := self.match(r); route != nil {
+		name = route.name
 	}
 	return name
 }
@@ -140,74 +257,240 @@ func (self *server) Use(modules ...func(http.Handler) http.Handler) {
 
 // Get is a shortcut for mux.HandleFunc(pattern, handler).Methods("GET"),
 // it also fetch the full function name of the handler (with package) to name the route.
-func (self *server) Get(pattern string, handler interface{}) {
-	self.register(pattern, handler, "GET")
+// The optional middleware modules wrap the handler of this route only, which run
+// after the middleware stack of the server/group, same as Route.Use.
+func (self *server) Get(pattern string, handler interface{}, modules ...func(http.Handler) http.Handler) *Route {
+	return self.register(pattern, handler, "GET").Use(modules...)
 }
 
 // Head is a shortcut for mux.HandleFunc(pattern, handler).Methods("HEAD")
 // it also fetch the full function name of the handler (with package) to name the route.
-func (self *server) Head(pattern string, handler interface{}) {
-	self.register(pattern, handler, "HEAD")
+func (self *server) Head(pattern string, handler interface{}, modules ...func(http.Handler) http.Handler) *Route {
+	return self.register(pattern, handler, "HEAD").Use(modules...)
 }
 
 // Options is a shortcut for mux.HandleFunc(pattern, handler).Methods("OPTIONS")
 // it also fetch the full function name of the handler (with package) to name the route.
 // NOTE method OPTIONS is **NOT** cachable, beware of what you are going to do.
-func (self *server) Options(pattern string, handler interface{}) {
-	self.register(pattern, handler, "OPTIONS")
+func (self *server) Options(pattern string, handler interface{}, modules ...func(http.Handler) http.Handler) *Route {
+	return self.register(pattern, handler, "OPTIONS").Use(modules...)
 }
 
 // POST is a shortcut for mux.HandleFunc(pattern, handler).Methods("POST")
 // it also fetch the full function name of the handler (with package) to name the route.
-func (self *server) Post(pattern string, handler interface{}) {
-	self.register(pattern, handler, "POST")
+func (self *server) Post(pattern string, handler interface{}, modules ...func(http.Handler) http.Handler) *Route {
+	return self.register(pattern, handler, "POST").Use(modules...)
 }
 
 // Put is a shortcut for mux.HandleFunc(pattern, handler).Methods("PUT")
 // it also fetch the full function name of the handler (with package) to name the route.
-func (self *server) Put(pattern string, handler interface{}) {
-	self.register(pattern, handler, "PUT")
+func (self *server) Put(pattern string, handler interface{}, modules ...func(http.Handler) http.Handler) *Route {
+	return self.register(pattern, handler, "PUT").Use(modules...)
 }
 
 // Delete is a shortcut for mux.HandleFunc(pattern, handler).Methods("DELETE")
 // it also fetch the full function name of the handler (with package) to name the route.
-func (self *server) Delete(pattern string, handler interface{}) {
-	self.register(pattern, handler, "DELETE")
+func (self *server) Delete(pattern string, handler interface{}, modules ...func(http.Handler) http.Handler) *Route {
+	return self.register(pattern, handler, "DELETE").Use(modules...)
 }
 
 // Trace is a shortcut for mux.HandleFunc(pattern, handler).Methods("TRACE")
 // it also fetch the full function name of the handler (with package) to name the route.
-func (self *server) Trace(pattern string, handler interface{}) {
-	self.register(pattern, handler, "TRACE")
+func (self *server) Trace(pattern string, handler interface{}, modules ...func(http.Handler) http.Handler) *Route {
+	return self.register(pattern, handler, "TRACE").Use(modules...)
 }
 
 // Connect is a shortcut for mux.HandleFunc(pattern, handler).Methods("CONNECT")
 // it also fetch the full function name of the handler (with package) to name the route.
-func (self *server) Connect(pattern string, handler interface{}) {
-	self.register(pattern, handler, "CONNECT")
+func (self *server) Connect(pattern string, handler interface{}, modules ...func(http.Handler) http.Handler) *Route {
+	return self.register(pattern, handler, "CONNECT").Use(modules...)
 }
 
 // ServeHTTP dispatches the request to the handler whose
 // pattern most closely matches the request URL.
 func (self *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if internal.FromRequest(r) == nil {
+		ctx := &internal.Context{Debug: self.config.Debug, ETag: self.config.ETag, Error: self.error, Lookup: self.resolve, Templates: self.views()}
+		r = internal.WithContext(r, ctx)
+		defer ctx.Finish()
+	}
 	self.build().ServeHTTP(w, r)
 }
 
+// OnShutdown registers the hooks to be executed (in order) once the server
+// stops accepting new connections and all active requests were drained,
+// e.g. closing database pools, flushing logs.
+func (self *server) OnShutdown(hooks ...func()) {
+	self.hooks = append(self.hooks, hooks...)
+}
+
+// Shutdown gracefully stops the application server: it closes the listener,
+// waits for active requests to complete until the context is done, then
+// executes the registered shutdown hooks. Subsequent calls wait for the
+// first one to complete & return its result, the hooks are executed once.
+func (self *server) Shutdown(ctx context.Context) error {
+	self.shutdown.Do(func() {
+		self.mutex.Lock()
+		app, redirect := self.http, self.redirect
+		self.mutex.Unlock()
+
+		if redirect != nil {
+			redirect.Shutdown(ctx)
+		}
+		if app != nil {
+			self.shutdownErr = app.Shutdown(ctx)
+		}
+		for _, hook := range self.hooks {
+			hook()
+		}
+	})
+	return self.shutdownErr
+}
+
+// serve accepts incoming connections on the listener until the server failed,
+// or SIGINT/SIGTERM received, which triggers the graceful shutdown, the server
+// will also be upgraded (see `upgrade`) on SIGUSR2 before shutting down.
+func (self *server) serve(listener net.Listener) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	upgrades := make(chan os.Signal, 1)
+	if len(upgradeSignals) > 0 {
+		signal.Notify(upgrades, upgradeSignals...)
+		defer signal.Stop(upgrades)
+	}
+
+	errs := make(chan error, 2)
+	if err := self.start(listener, errs); err != nil {
+		return err
+	}
+	// inform the previous process (if any) to shut down.
+	notify()
+
+	for {
+		select {
+		case err := <-errs:
+			if err == http.ErrServerClosed {
+				// Shutdown was called elsewhere, wait for the active requests & hooks.
+				return self.Shutdown(context.Background())
+			}
+			return err
+
+		case sig := <-signals:
+			log.Infof("Received %v, shutting down the server", sig)
+			return self.drain()
+
+		case <-upgrades:
+			log.Infof("Upgrading the server")
+			if err := self.upgrade(); err != nil {
+				log.Errorf("Failed to upgrade the server: %v", err)
+			} else {
+				log.Infof("Server upgraded, shutting down the previous one")
+				return self.drain()
+			}
+		}
+	}
+}
+
+// start serves the listener (along with the HTTP => HTTPS redirection, if enabled)
+// in the background, the failures are sent to errs.
+func (self *server) start(listener net.Listener, errs chan<- error) error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	self.sockets = []net.Listener{listener}
+	self.listener = newListener(listener, self.config.MaxConns)
+	self.http = self.httpServer(self)
+	if self.config.Secure() {
+		config, err := tlsConfig(self.config.TLSCert, self.config.TLSKey, self.config.HTTP2)
+		if err != nil {
+			return err
+		}
+		self.http.TLSConfig = config
+		if !self.config.HTTP2 {
+			// non-nil, empty map disables the HTTP/2 supports.
+			self.http.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler))
+		}
+		if self.config.RedirectPort > 0 {
+			redirector, err := listen(net.JoinHostPort(self.config.host(), strconv.Itoa(self.config.RedirectPort)), 1)
+			if err != nil {
+				return err
+			}
+			self.sockets = append(self.sockets, redirector)
+			self.redirect = self.httpServer(redirectTLS(self.config.Port))
+			go func() {
+				errs <- self.redirect.Serve(newListener(redirector, self.config.MaxConns))
+			}()
+		}
+		go func() {
+			errs <- self.http.ServeTLS(self.listener, "", "")
+		}()
+
+	} else {
+		go func() {
+			errs <- self.http.Serve(self.listener)
+		}()
+	}
+	return nil
+}
+
+// httpServer creates the http.Server of the handler with the configured timeouts & limits.
+func (self *server) httpServer(handler http.Handler) *http.Server {
+	return &http.Server{
+		Handler:           handler,
+		ReadTimeout:       self.config.ReadTimeout,
+		ReadHeaderTimeout: self.config.ReadHeaderTimeout,
+		WriteTimeout:      self.config.WriteTimeout,
+		IdleTimeout:       self.config.IdleTimeout,
+		MaxHeaderBytes:    self.config.MaxHeaderBytes,
+	}
+}
+
+// drain gracefully shuts down the server within the configured timeout.
+func (self *server) drain() error {
+	ctx, cancel := context.WithTimeout(context.Background(), self.config.ShutdownTimeout)
+	defer cancel()
+	return self.Shutdown(ctx)
+}
+
+// ConnStats returns the connection metrics of the running application server.
+func (self *server) ConnStats() (stats ConnStats) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if self.listener != nil {
+		stats = self.listener.stats()
+	}
+	return
+}
+
 // Run starts the application server to serve incoming requests at the given address.
 func (self *server) Run() {
-	runtime.GOMAXPROCS(maxprocs)
-
-	go func() {
-		time.Sleep(500 * time.Millisecond)
-		log.Infof("Application server is listening at %d", port)
-	}()
+	// * `rex routes` inspection mode.
+	if format := env.String(internal.Routes, ""); format != "" {
+		if err := self.printRoutes(os.Stdout, format); err != nil {
+			log.Fatalf("Failed to print the routes: %v", err)
+		}
+		return
+	}
+	runtime.GOMAXPROCS(self.config.MaxProcs)
 
-	if err := http.ListenAndServe(fmt.Sprintf(":%d", port), self); err != nil {
+	listener, err := listen(self.config.Addr(), 0)
+	if err != nil {
 		log.Fatalf("Failed to start the server: %v", err)
 	}
+	if self.config.Secure() {
+		log.Infof("Application server is listening at %s (HTTPS)", self.config.Addr())
+	} else {
+		log.Infof("Application server is listening at %s", self.config.Addr())
+	}
+
+	if err := self.serve(listener); err != nil {
+		log.Fatalf("Failed to stop the server: %v", err)
+	}
 }
 
 // Vars returns the route variables for the current request, if any.
 func (self *server) Vars(r *http.Request) map[string]string {
-	return mux.Vars(r)
+	return Vars(r)
 }
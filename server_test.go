@@ -1,32 +1,23 @@
 package rex
 
 import (
+	"context"
 	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path"
+	"sync/atomic"
+	"syscall"
 	"testing"
+	"time"
 
-	"github.com/goanywhere/env"
 	mw "github.com/goanywhere/rex/middleware"
 	. "github.com/smartystreets/goconvey/convey"
 )
 
-func TestConfigure(t *testing.T) {
-	Convey("rex.configure", t, func() {
-		app := New()
-		app.configure()
-
-		So(debug, ShouldBeTrue)
-		So(port, ShouldEqual, 5000)
-
-		env.Set("PORT", 9394)
-		app.configure()
-		So(port, ShouldEqual, 5000)
-	})
-}
-
 func TestBuild(t *testing.T) {
 	Convey("rex.build", t, func() {
 		app := New()
@@ -294,13 +285,13 @@ func TestHost(t *testing.T) {
 		w.Header().Set("X-Powered-By", "base")
 	})
 
-  user := app.Host("localhost")
+	user := app.Host("localhost")
 	user.Get("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("X-Powered-By", "rex")
 	})
 
 	Convey("rex.Host", t, func() {
-    request, _ := http.NewRequest("GET", "http://localhost/", nil)
+		request, _ := http.NewRequest("GET", "http://localhost/", nil)
 		response := httptest.NewRecorder()
 
 		app.ServeHTTP(response, request)
@@ -370,3 +361,226 @@ func TestVars(t *testing.T) {
 		app.ServeHTTP(response, request)
 	})
 }
+
+func TestShutdown(t *testing.T) {
+	Convey("rex.Shutdown", t, func() {
+		var (
+			started = make(chan bool)
+			hooked  bool
+		)
+		app := New()
+		app.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, "index")
+		})
+		app.Get("/slow", func(w http.ResponseWriter, r *http.Request) {
+			started <- true
+			time.Sleep(300 * time.Millisecond)
+			io.WriteString(w, "slow")
+		})
+		app.OnShutdown(func() {
+			hooked = true
+		})
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		address := "http://" + listener.Addr().String()
+
+		served := make(chan error, 1)
+		go func() {
+			served <- app.serve(listener)
+		}()
+
+		// ensure the server is up (signals are being watched) before going further.
+		response, err := http.Get(address + "/")
+		So(err, ShouldBeNil)
+		response.Body.Close()
+
+		type result struct {
+			body string
+			err  error
+		}
+		done := make(chan result, 1)
+		go func() {
+			response, err := http.Get(address + "/slow")
+			if err != nil {
+				done <- result{err: err}
+				return
+			}
+			defer response.Body.Close()
+			body, err := ioutil.ReadAll(response.Body)
+			done <- result{string(body), err}
+		}()
+
+		<-started
+		syscall.Kill(os.Getpid(), syscall.SIGTERM)
+
+		So(<-served, ShouldBeNil)
+		So(hooked, ShouldBeTrue)
+
+		slow := <-done
+		So(slow.err, ShouldBeNil)
+		So(slow.body, ShouldEqual, "slow")
+
+		_, err = http.Get(address + "/")
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("rex.Shutdown (called elsewhere)", t, func() {
+		var (
+			started = make(chan bool)
+			hooks   int32
+			slow    = make(chan string, 1)
+		)
+		app := New()
+		app.Get("/slow", func(w http.ResponseWriter, r *http.Request) {
+			started <- true
+			time.Sleep(300 * time.Millisecond)
+			io.WriteString(w, "slow")
+		})
+		app.OnShutdown(func() {
+			atomic.AddInt32(&hooks, 1)
+		})
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		served := make(chan error, 1)
+		go func() {
+			served <- app.serve(listener)
+		}()
+		go func() {
+			response, err := http.Get("http://" + listener.Addr().String() + "/slow")
+			if err != nil {
+				slow <- err.Error()
+				return
+			}
+			defer response.Body.Close()
+			body, _ := ioutil.ReadAll(response.Body)
+			slow <- string(body)
+		}()
+
+		<-started
+		for i := 0; i < 2; i++ {
+			go app.Shutdown(context.Background())
+		}
+		// serve returns only once the active requests drained & hooks executed.
+		So(<-served, ShouldBeNil)
+		So(atomic.LoadInt32(&hooks), ShouldEqual, 1)
+		So(<-slow, ShouldEqual, "slow")
+
+		So(app.Shutdown(context.Background()), ShouldBeNil)
+		So(atomic.LoadInt32(&hooks), ShouldEqual, 1)
+	})
+}
+
+func TestRouteMiddleware(t *testing.T) {
+	var trace []string
+	tracer := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				trace = append(trace, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	app := New()
+	app.Use(tracer("server"))
+	app.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		trace = append(trace, "index")
+	})
+	app.Get("/admin", func(w http.ResponseWriter, r *http.Request) {
+		trace = append(trace, "admin")
+	}, tracer("auth"), tracer("limit"))
+
+	user := app.Group("/users")
+	user.Use(tracer("group"))
+	user.Post("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		trace = append(trace, "user")
+	}), tracer("auth"))
+
+	Convey("rex.Get (route middleware)", t, func() {
+		trace = nil
+		request, _ := http.NewRequest("GET", "/admin", nil)
+		app.ServeHTTP(httptest.NewRecorder(), request)
+		So(trace, ShouldResemble, []string{"server", "auth", "limit", "admin"})
+
+		trace = nil
+		request, _ = http.NewRequest("GET", "/", nil)
+		app.ServeHTTP(httptest.NewRecorder(), request)
+		So(trace, ShouldResemble, []string{"server", "index"})
+
+		trace = nil
+		request, _ = http.NewRequest("POST", "/users/", nil)
+		app.ServeHTTP(httptest.NewRecorder(), request)
+		So(trace, ShouldResemble, []string{"server", "group", "auth", "user"})
+
+		So(func() {
+			app.Get("/panic", nil, tracer("auth"))
+		}, ShouldPanic)
+	})
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Method)
+	}
+	app := New()
+	app.Get("/users/{id}", handler)
+	app.Put("/users/{id}", handler)
+	app.Get("/posts", handler)
+	app.Options("/posts", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", "custom")
+	})
+
+	user := app.Group("/v1")
+	user.Post("/accounts", handler)
+
+	blog := app.Host("blog.example.com")
+	blog.Get("/archives", handler)
+
+	Convey("rex.dispatch (405)", t, func() {
+		request, _ := http.NewRequest("POST", "/users/1", nil)
+		response := httptest.NewRecorder()
+		app.ServeHTTP(response, request)
+		So(response.Code, ShouldEqual, http.StatusMethodNotAllowed)
+		So(response.Header().Get("Allow"), ShouldEqual, "GET, PUT, OPTIONS")
+
+		request, _ = http.NewRequest("GET", "/v1/accounts", nil)
+		response = httptest.NewRecorder()
+		app.ServeHTTP(response, request)
+		So(response.Code, ShouldEqual, http.StatusMethodNotAllowed)
+		So(response.Header().Get("Allow"), ShouldEqual, "POST, OPTIONS")
+
+		request, _ = http.NewRequest("DELETE", "http://blog.example.com/archives", nil)
+		response = httptest.NewRecorder()
+		app.ServeHTTP(response, request)
+		So(response.Code, ShouldEqual, http.StatusMethodNotAllowed)
+		So(response.Header().Get("Allow"), ShouldEqual, "GET, OPTIONS")
+
+		request, _ = http.NewRequest("POST", "/unknown", nil)
+		response = httptest.NewRecorder()
+		app.ServeHTTP(response, request)
+		So(response.Code, ShouldEqual, http.StatusNotFound)
+		So(response.Header().Get("Allow"), ShouldBeEmpty)
+	})
+
+	Convey("rex.dispatch (OPTIONS)", t, func() {
+		request, _ := http.NewRequest("OPTIONS", "/users/1", nil)
+		response := httptest.NewRecorder()
+		app.ServeHTTP(response, request)
+		So(response.Code, ShouldEqual, http.StatusOK)
+		So(response.Header().Get("Allow"), ShouldEqual, "GET, PUT, OPTIONS")
+
+		request, _ = http.NewRequest("OPTIONS", "/v1/accounts", nil)
+		response = httptest.NewRecorder()
+		app.ServeHTTP(response, request)
+		So(response.Code, ShouldEqual, http.StatusOK)
+		So(response.Header().Get("Allow"), ShouldEqual, "POST, OPTIONS")
+
+		// registered OPTIONS handler takes precedence.
+		request, _ = http.NewRequest("OPTIONS", "/posts", nil)
+		response = httptest.NewRecorder()
+		app.ServeHTTP(response, request)
+		So(response.Header().Get("Allow"), ShouldEqual, "custom")
+	})
+}
@@ -0,0 +1,109 @@
+package rex
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// certificate holds the X.509 key pair loaded from the given files, the pair
+// will be reloaded whenever the files changed on disk, so renewed certificates
+// can be picked up without restarting the application server.
+type certificate struct {
+	certFile string
+	keyFile  string
+
+	mutex   sync.RWMutex
+	cert    *tls.Certificate
+	modtime time.Time
+}
+
+func newCertificate(certFile, keyFile string) (*certificate, error) {
+	self := &certificate{certFile: certFile, keyFile: keyFile}
+	if err := self.load(); err != nil {
+		return nil, err
+	}
+	return self, nil
+}
+
+// modified returns the latest modification time of the certificate/key files.
+func (self *certificate) modified() (modtime time.Time) {
+	for _, filename := range []string{self.certFile, self.keyFile} {
+		if info, err := os.Stat(filename); err == nil && info.ModTime().After(modtime) {
+			modtime = info.ModTime()
+		}
+	}
+	return
+}
+
+// load (re)reads the key pair from files, failed attempts are recorded as well,
+// so the same files are not re-read on every handshake until changed again.
+func (self *certificate) load() error {
+	modtime := self.modified()
+	cert, err := tls.LoadX509KeyPair(self.certFile, self.keyFile)
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.modtime = modtime
+	if err != nil {
+		return err
+	}
+	self.cert = &cert
+	return nil
+}
+
+// GetCertificate serves as tls.Config.GetCertificate, the previously loaded
+// key pair will be kept in use if the changed files can not be reloaded.
+func (self *certificate) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	self.mutex.RLock()
+	modtime := self.modtime
+	self.mutex.RUnlock()
+
+	if self.modified().After(modtime) {
+		if err := self.load(); err != nil {
+			log.Errorf("Failed to reload the certificate: %v", err)
+		} else {
+			log.Infof("Certificate %s reloaded", self.certFile)
+		}
+	}
+
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
+	return self.cert, nil
+}
+
+// tlsConfig constructs the TLS configurations with the given certificate files.
+func tlsConfig(certFile, keyFile string, h2 bool) (*tls.Config, error) {
+	cert, err := newCertificate(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		GetCertificate: cert.GetCertificate,
+		MinVersion:     tls.VersionTLS12,
+		NextProtos:     []string{"http/1.1"},
+	}
+	if h2 {
+		config.NextProtos = []string{"h2", "http/1.1"}
+	}
+	return config, nil
+}
+
+// redirectTLS redirects the plain HTTP requests to their HTTPS counterparts.
+func redirectTLS(port int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if name, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = name
+		}
+		if port != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(port))
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
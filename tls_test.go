@@ -0,0 +1,176 @@
+package rex
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// createCertificate writes a self-signed certificate/key pair for localhost.
+func createCertificate(dir, name string) (certFile, keyFile string) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, _ := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	raw, _ := x509.MarshalECPrivateKey(key)
+
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: raw}), 0600)
+	return
+}
+
+func TestCertificate(t *testing.T) {
+	Convey("rex.certificate", t, func() {
+		dir, _ := ioutil.TempDir("", "rex")
+		defer os.RemoveAll(dir)
+
+		certFile, keyFile := createCertificate(dir, "origin")
+		cert, err := newCertificate(certFile, keyFile)
+		So(err, ShouldBeNil)
+
+		config, err := tlsConfig(certFile, keyFile, false)
+		So(err, ShouldBeNil)
+		So(config.MinVersion, ShouldEqual, tls.VersionTLS12)
+
+		origin, err := cert.GetCertificate(nil)
+		So(err, ShouldBeNil)
+
+		// renewed certificate should be reloaded.
+		createCertificate(dir, "renewed")
+		future := time.Now().Add(time.Minute)
+		os.Chtimes(certFile, future, future)
+
+		renewed, err := cert.GetCertificate(nil)
+		So(err, ShouldBeNil)
+		So(renewed, ShouldNotEqual, origin)
+
+		leaf, _ := x509.ParseCertificate(renewed.Certificate[0])
+		So(leaf.Subject.CommonName, ShouldEqual, "renewed")
+
+		// broken certificate should keep the previous one in use.
+		ioutil.WriteFile(certFile, []byte("broken"), 0600)
+		future = future.Add(time.Minute)
+		os.Chtimes(certFile, future, future)
+
+		current, err := cert.GetCertificate(nil)
+		So(err, ShouldBeNil)
+		So(current, ShouldEqual, renewed)
+		// the failed attempt is not retried until changed again.
+		So(cert.modtime.Equal(cert.modified()), ShouldBeTrue)
+	})
+}
+
+func TestServeTLS(t *testing.T) {
+	Convey("rex.serve (HTTPS)", t, func() {
+		dir, _ := ioutil.TempDir("", "rex")
+		defer os.RemoveAll(dir)
+
+		config := NewConfig()
+		config.TLSCert, config.TLSKey = createCertificate(dir, "localhost")
+
+		app := NewWithConfig(config)
+		app.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, r.Proto)
+		})
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		go app.serve(listener)
+		defer app.Shutdown(context.Background())
+
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}}
+		response, err := client.Get("https://" + listener.Addr().String() + "/")
+		So(err, ShouldBeNil)
+		body, _ := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+		So(string(body), ShouldEqual, "HTTP/1.1")
+
+		conn, err := tls.Dial("tcp", listener.Addr().String(), &tls.Config{
+			InsecureSkipVerify: true,
+			NextProtos:         []string{"h2", "http/1.1"},
+		})
+		So(err, ShouldBeNil)
+		So(conn.ConnectionState().NegotiatedProtocol, ShouldEqual, "h2")
+		conn.Close()
+	})
+
+	Convey("rex.serve (HTTP => HTTPS redirection)", t, func() {
+		dir, _ := ioutil.TempDir("", "rex")
+		defer os.RemoveAll(dir)
+
+		// reserve a free port for the redirection.
+		reserved, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		reserved.Close()
+
+		config := NewConfig()
+		config.TLSCert, config.TLSKey = createCertificate(dir, "localhost")
+		config.Address = "127.0.0.1"
+		config.RedirectPort = reserved.Addr().(*net.TCPAddr).Port
+		config.ReadHeaderTimeout = 100 * time.Millisecond
+		app := NewWithConfig(config)
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		go app.serve(listener)
+		defer app.Shutdown(context.Background())
+
+		// slow clients are dropped once the header timeout exceeded.
+		var conn net.Conn
+		for attempt := 0; attempt < 50; attempt++ {
+			if conn, err = net.Dial("tcp", reserved.Addr().String()); err == nil {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		So(err, ShouldBeNil)
+		defer conn.Close()
+		io.WriteString(conn, "GET / HTTP/1.1\r\nHost: localhost\r\n")
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		_, err = ioutil.ReadAll(conn)
+		So(err, ShouldBeNil)
+	})
+}
+
+func TestRedirectTLS(t *testing.T) {
+	Convey("rex.redirectTLS", t, func() {
+		request, _ := http.NewRequest("GET", "http://example.com:8080/users?page=2", nil)
+		response := httptest.NewRecorder()
+		redirectTLS(443).ServeHTTP(response, request)
+		So(response.Code, ShouldEqual, http.StatusMovedPermanently)
+		So(response.Header().Get("Location"), ShouldEqual, "https://example.com/users?page=2")
+
+		response = httptest.NewRecorder()
+		redirectTLS(8443).ServeHTTP(response, request)
+		So(response.Header().Get("Location"), ShouldEqual, "https://example.com:8443/users?page=2")
+	})
+}
@@ -0,0 +1,107 @@
+package rex
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/goanywhere/env"
+	"github.com/goanywhere/rex/internal"
+)
+
+// upgradeTimeout is the maximum duration to wait for the new process getting ready.
+const upgradeTimeout = time.Minute
+
+// notify reports the parent process (if any) that the server
+// is ready to accept connections on the inherited sockets.
+func notify() {
+	fd := env.Int(internal.ReadyFd, 0)
+	if fd <= 0 {
+		return
+	}
+	os.Unsetenv(internal.ReadyFd)
+	if file := os.NewFile(uintptr(fd), "ready"); file != nil {
+		file.Write([]byte{1})
+		file.Close()
+	}
+}
+
+// upgrade starts the (possibly rebuilt) binary of the application as a new process,
+// with the listening sockets handed off via LISTEN_FDS, returns once the new process
+// reported ready to accept connections, so the current one can be drained safely.
+func (self *server) upgrade() error {
+	var files []*os.File
+	defer func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}()
+	for _, socket := range self.sockets {
+		listener, ok := socket.(interface {
+			File() (*os.File, error)
+		})
+		if !ok {
+			return fmt.Errorf("unsupported listener: %v", socket.Addr())
+		}
+		file, err := listener.File()
+		if err != nil {
+			return err
+		}
+		files = append(files, file)
+	}
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	executable, err := os.Executable()
+	if err != nil {
+		writer.Close()
+		return err
+	}
+	command := exec.Command(executable, os.Args[1:]...)
+	command.Stdin = os.Stdin
+	command.Stdout = os.Stdout
+	command.Stderr = os.Stderr
+	command.ExtraFiles = append(files, writer)
+	command.Env = append(os.Environ(),
+		fmt.Sprintf("LISTEN_FDS=%d", len(files)),
+		fmt.Sprintf("%s=%d", internal.ReadyFd, listenFdsStart+len(files)),
+	)
+	err = command.Start()
+	writer.Close()
+	if err != nil {
+		return err
+	}
+
+	ready := make(chan error, 1)
+	go func() {
+		// EOF if the new process exited without reporting ready.
+		_, err := reader.Read(make([]byte, 1))
+		ready <- err
+	}()
+
+	select {
+	case err = <-ready:
+	case <-time.After(upgradeTimeout):
+		err = errors.New("timeout")
+	}
+	if err != nil {
+		command.Process.Kill()
+		command.Wait()
+		return fmt.Errorf("new process (%d) failed to get ready: %v", command.Process.Pid, err)
+	}
+
+	// the socket files are owned by the new process now.
+	for _, socket := range self.sockets {
+		if listener, ok := socket.(*net.UnixListener); ok {
+			listener.SetUnlinkOnClose(false)
+		}
+	}
+	return nil
+}
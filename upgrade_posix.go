@@ -0,0 +1,12 @@
+//go:build !windows
+// +build !windows
+
+package rex
+
+import (
+	"os"
+	"syscall"
+)
+
+// upgradeSignals triggers the zero-downtime binary upgrade.
+var upgradeSignals = []os.Signal{syscall.SIGUSR2}
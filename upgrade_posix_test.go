@@ -0,0 +1,107 @@
+//go:build !windows
+// +build !windows
+
+package rex
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"github.com/goanywhere/rex/internal"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// upgradeHelper makes the test binary serve as the new process of upgrade, see TestMain.
+const upgradeHelper = "REX_TEST_UPGRADE"
+
+func TestMain(m *testing.M) {
+	switch os.Getenv(upgradeHelper) {
+	case "ready":
+		// take over the inherited socket & answer a single connection with the pid.
+		listeners, err := inherited()
+		if err != nil || len(listeners) != 1 {
+			os.Exit(2)
+		}
+		notify()
+		conn, err := listeners[0].Accept()
+		if err != nil {
+			os.Exit(3)
+		}
+		conn.Write([]byte(strconv.Itoa(os.Getpid())))
+		conn.Close()
+		os.Exit(0)
+	case "fail":
+		os.Exit(1)
+	}
+	os.Exit(m.Run())
+}
+
+func TestUpgrade(t *testing.T) {
+	Convey("rex.server.upgrade", t, func() {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		app := New()
+		app.sockets = []net.Listener{listener}
+
+		os.Setenv(upgradeHelper, "ready")
+		err = app.upgrade()
+		os.Unsetenv(upgradeHelper)
+		So(err, ShouldBeNil)
+
+		// the new process keeps accepting on the socket once the current one stopped.
+		listener.Close()
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		So(err, ShouldBeNil)
+		defer conn.Close()
+		data, err := ioutil.ReadAll(conn)
+		So(err, ShouldBeNil)
+		pid, err := strconv.Atoi(string(data))
+		So(err, ShouldBeNil)
+		So(pid, ShouldNotEqual, os.Getpid())
+	})
+
+	Convey("rex.server.upgrade (failed)", t, func() {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		defer listener.Close()
+		app := New()
+		app.sockets = []net.Listener{listener}
+
+		os.Setenv(upgradeHelper, "fail")
+		err = app.upgrade()
+		os.Unsetenv(upgradeHelper)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "failed to get ready")
+	})
+}
+
+func TestNotify(t *testing.T) {
+	Convey("rex.notify", t, func() {
+		reader, writer, err := os.Pipe()
+		So(err, ShouldBeNil)
+		defer reader.Close()
+
+		// hand over a duplicated descriptor, as it will be closed by notify.
+		fd, err := syscall.Dup(int(writer.Fd()))
+		So(err, ShouldBeNil)
+		writer.Close()
+
+		os.Setenv(internal.ReadyFd, fmt.Sprintf("%d", fd))
+		notify()
+		So(os.Getenv(internal.ReadyFd), ShouldBeEmpty)
+
+		buffer := make([]byte, 2)
+		size, err := reader.Read(buffer)
+		So(err, ShouldBeNil)
+		So(size, ShouldEqual, 1)
+
+		// writer should be closed by notify.
+		_, err = reader.Read(buffer)
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,6 @@
+package rex
+
+import "os"
+
+// upgradeSignals triggers the zero-downtime binary upgrade (not supported).
+var upgradeSignals []os.Signal
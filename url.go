@@ -0,0 +1,70 @@
+package rex
+
+import (
+	"fmt"
+	"html/template"
+	"net/url"
+)
+
+// root returns the top-level application server.
+func (self *server) root() *server {
+	for self.parent != nil {
+		self = self.parent
+	}
+	return self
+}
+
+// lookup finds the route with the given name, including subservers.
+func (self *server) lookup(name string) *Route {
+	for _, route := range self.routes {
+		if route.name == name {
+			return route
+		}
+	}
+	for _, server := range self.subservers {
+		if route := server.lookup(name); route != nil {
+			return route
+		}
+	}
+	return nil
+}
+
+// URL builds the URL for the named route (across all groups/hosts of the application),
+// with the given route variables in key/value pairs, e.g.
+//
+//	app.Get("/users/{id:[0-9]+}", user).Name("user")
+//	app.URL("user", "id", "123") // => /users/123
+func (self *server) URL(name string, pairs ...string) (*url.URL, error) {
+	route := self.root().lookup(name)
+	if route == nil {
+		return nil, fmt.Errorf("rex: route %q not found", name)
+	}
+	address, err := route.route.URL(pairs...)
+	if err != nil {
+		return nil, fmt.Errorf("rex: failed to build URL for route %q: %v", name, err)
+	}
+	return address, nil
+}
+
+// URLFor builds the URL string for the named route, route variables can be
+// given in any type (e.g. int), see URL for further details.
+func (self *server) URLFor(name string, pairs ...interface{}) (string, error) {
+	var values = make([]string, len(pairs))
+	for index, value := range pairs {
+		values[index] = fmt.Sprint(value)
+	}
+	address, err := self.URL(name, values...)
+	if err != nil {
+		return "", err
+	}
+	return address.String(), nil
+}
+
+// FuncMap returns the helpers for HTML templates, e.g.
+//
+//	<a href="{{ URLFor "user" "id" .ID }}">profile</a>
+func (self *server) FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"URLFor": self.URLFor,
+	}
+}
@@ -0,0 +1,73 @@
+package rex
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestURL(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {}
+
+	app := New()
+	app.Get("/", handler).Name("index")
+	app.Get("/users/{id:[0-9]+}", handler).Name("user")
+
+	api := app.Group("/v1")
+	api.Get("/posts/{slug}", handler).Name("post")
+
+	blog := app.Host("{name}.example.com")
+	blog.Get("/archives/{year}", handler).Name("archives")
+
+	Convey("rex.URL", t, func() {
+		address, err := app.URL("index")
+		So(err, ShouldBeNil)
+		So(address.String(), ShouldEqual, "/")
+
+		address, err = app.URL("user", "id", "123")
+		So(err, ShouldBeNil)
+		So(address.String(), ShouldEqual, "/users/123")
+
+		// across groups/hosts, from any server.
+		address, err = api.URL("user", "id", "123")
+		So(err, ShouldBeNil)
+		So(address.String(), ShouldEqual, "/users/123")
+
+		address, err = app.URL("post", "slug", "hello")
+		So(err, ShouldBeNil)
+		So(address.String(), ShouldEqual, "/v1/posts/hello")
+
+		address, err = api.URL("archives", "name", "rex", "year", "2015")
+		So(err, ShouldBeNil)
+		So(address.String(), ShouldEqual, "http://rex.example.com/archives/2015")
+
+		_, err = app.URL("unknown")
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, `"unknown" not found`)
+
+		_, err = app.URL("user")
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, `route "user"`)
+
+		_, err = app.URL("user", "id", "abc")
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("rex.URLFor", t, func() {
+		address, err := app.URLFor("user", "id", 123)
+		So(err, ShouldBeNil)
+		So(address, ShouldEqual, "/users/123")
+
+		var buffer = new(bytes.Buffer)
+		html := template.Must(template.New("index").Funcs(app.FuncMap()).Parse(`<a href="{{ URLFor "user" "id" .ID }}">me</a>`))
+		err = html.Execute(buffer, struct{ ID int }{123})
+		So(err, ShouldBeNil)
+		So(buffer.String(), ShouldEqual, `<a href="/users/123">me</a>`)
+
+		err = html.Execute(new(bytes.Buffer), struct{ ID string }{"me"})
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,272 @@
+// Package view renders the HTML templates under the views directory, with layouts,
+// partials & blocks, e.g.
+//
+//	app := rex.New()
+//	app.Templates(view.New().Funcs(app.FuncMap()))
+//	app.Get("/", func(w http.ResponseWriter, r *http.Request) error {
+//		return rex.HTML(w, r, http.StatusOK, "index.html", data)
+//	})
+package view
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goanywhere/env"
+	"github.com/goanywhere/rex/middleware"
+)
+
+var directive = regexp.MustCompile(`^\s*\{\{/\*\s*layout:\s*(\S+)\s*\*/\}\}`)
+
+// Engine loads & renders the HTML templates under the views directory:
+//
+//	views/layouts/base.html   layouts, define the blocks, e.g. {{block "content" .}}{{end}}
+//	views/partials/nav.html   partials, available to all pages, e.g. {{template "partials/nav.html" .}}
+//	views/users/show.html     pages, override the blocks of the layout, e.g. {{define "content"}}...{{end}}
+//
+// Pages render within the default layout (if exists), or the one chosen via the directive
+// {{/* layout: admin.html */}} on the first line, "none" to render the page alone.
+//
+// Compiled templates are cached, while they are re-parsed on change in debug mode.
+type Engine struct {
+	Dir       string // views directory, defaults to "views" under the basedir.
+	Layout    string // default layout under "layouts", defaults to "base.html".
+	Debug     bool   // re-parse the templates on change, defaults to DEBUG env.
+	Assets    string // URL prefix of the static assets, defaults to "/static/".
+	AssetsDir string // directory of the static assets, defaults to "static" under the basedir.
+
+	funcs   template.FuncMap
+	mutex   sync.RWMutex
+	pages   map[string]*page
+	modtime time.Time
+}
+
+// page is the compiled page along with its layout & partials.
+type page struct {
+	*template.Template
+	entry string    // name of the template to execute, i.e. layout or page itself.
+	pool  sync.Pool // executable instances, escaped once on their first execution.
+}
+
+// instance is the executable clone of the page, bound to the request-scoped helpers.
+type instance struct {
+	*template.Template
+	token string
+	field template.HTML
+}
+
+// instance returns an idle instance of the page, cloned from the compiled page if none,
+// as templates can no longer be cloned nor bound to other helpers once executed.
+func (self *page) instance() (*instance, error) {
+	if idle, ok := self.pool.Get().(*instance); ok {
+		return idle, nil
+	}
+	clone, err := self.Clone()
+	if err != nil {
+		return nil, err
+	}
+	var bound = &instance{Template: clone}
+	clone.Funcs(template.FuncMap{
+		"xsrfToken": func() string { return bound.token },
+		"xsrfField": func() template.HTML { return bound.field },
+	})
+	return bound, nil
+}
+
+// New creates a view engine with settings from env.
+func New() *Engine {
+	basedir := env.String("basedir", ".")
+	return &Engine{
+		Dir:       filepath.Join(basedir, "views"),
+		Layout:    "base.html",
+		Debug:     env.Bool("DEBUG", true),
+		Assets:    "/static/",
+		AssetsDir: filepath.Join(basedir, "static"),
+	}
+}
+
+// Funcs adds the helper functions to the templates, e.g. app.FuncMap() for URL reversal.
+func (self *Engine) Funcs(funcs template.FuncMap) *Engine {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if self.funcs == nil {
+		self.funcs = make(template.FuncMap)
+	}
+	for name, fn := range funcs {
+		self.funcs[name] = fn
+	}
+	self.pages = nil
+	return self
+}
+
+// Load (re-)compiles all templates under the views directory.
+func (self *Engine) Load() error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	return self.load()
+}
+
+// ExecuteTemplate renders the named page with data.
+func (self *Engine) ExecuteTemplate(w io.Writer, name string, data interface{}) error {
+	return self.ExecuteRequest(w, nil, name, data)
+}
+
+// ExecuteRequest renders the named page with data, along with the request-scoped helpers:
+//
+//	{{xsrfToken}}  XSRF token published by middleware.XSRF
+//	{{xsrfField}}  hidden form field carrying the XSRF token
+func (self *Engine) ExecuteRequest(w io.Writer, r *http.Request, name string, data interface{}) error {
+	page, err := self.lookup(name)
+	if err != nil {
+		return err
+	}
+	instance, err := page.instance()
+	if err != nil {
+		return err
+	}
+	defer page.pool.Put(instance)
+	instance.token, instance.field = "", ""
+	if r != nil {
+		_, instance.token = middleware.XSRFField(r)
+		instance.field = middleware.XSRFInput(r)
+	}
+	return instance.ExecuteTemplate(w, page.entry, data)
+}
+
+// lookup returns the compiled page, templates are (re-)loaded if not yet or changed in debug mode.
+func (self *Engine) lookup(name string) (*page, error) {
+	self.mutex.RLock()
+	pages, modtime := self.pages, self.modtime
+	self.mutex.RUnlock()
+
+	if pages == nil || self.Debug && self.changed(modtime) {
+		self.mutex.Lock()
+		err := self.load()
+		pages = self.pages
+		self.mutex.Unlock()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if page, ok := pages[name]; ok {
+		return page, nil
+	}
+	return nil, fmt.Errorf("view: template %q not found", name)
+}
+
+// changed reports whether any of the templates was modified since the given time.
+func (self *Engine) changed(since time.Time) bool {
+	var changed bool
+	filepath.Walk(self.Dir, func(filename string, info os.FileInfo, err error) error {
+		if err == nil && info.ModTime().After(since) {
+			changed = true
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return changed
+}
+
+// load compiles all pages along with their layouts & partials, the mutex must be held.
+func (self *Engine) load() error {
+	var layouts, partials, pages = make(map[string]string), make(map[string]string), make(map[string]string)
+	var modtime time.Time
+
+	err := filepath.Walk(self.Dir, func(filename string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.ModTime().After(modtime) {
+			modtime = info.ModTime()
+		}
+		if info.IsDir() || filepath.Ext(filename) != ".html" {
+			return nil
+		}
+		content, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		name, _ := filepath.Rel(self.Dir, filename)
+		name = filepath.ToSlash(name)
+		switch {
+		case strings.HasPrefix(name, "layouts/"):
+			layouts[name] = string(content)
+		case strings.HasPrefix(name, "partials/"):
+			partials[name] = string(content)
+		default:
+			pages[name] = string(content)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var compiled = make(map[string]*page)
+	for name, content := range pages {
+		tmpl := template.New(name).Funcs(self.helpers())
+		for partial, text := range partials {
+			if _, err := tmpl.New(partial).Parse(text); err != nil {
+				return err
+			}
+		}
+
+		var entry = name
+		var layout = "layouts/" + self.Layout
+		if match := directive.FindStringSubmatch(content); match != nil {
+			layout = "layouts/" + match[1]
+			if match[1] == "none" {
+				layout = ""
+			} else if _, ok := layouts[layout]; !ok {
+				return fmt.Errorf("view: layout %q of %q not found", match[1], name)
+			}
+		}
+		if text, ok := layouts[layout]; ok {
+			if _, err := tmpl.New(layout).Parse(text); err != nil {
+				return err
+			}
+			entry = layout
+		}
+
+		// pages are parsed last to override the blocks of the layout.
+		if _, err := tmpl.Parse(content); err != nil {
+			return err
+		}
+		compiled[name] = &page{Template: tmpl, entry: entry}
+	}
+	self.pages, self.modtime = compiled, modtime
+	return nil
+}
+
+// helpers returns the helper functions available to all templates.
+func (self *Engine) helpers() template.FuncMap {
+	var funcs = template.FuncMap{
+		"asset": self.asset,
+		// request-scoped helpers, see ExecuteRequest.
+		"xsrfToken": func() string { return "" },
+		"xsrfField": func() template.HTML { return "" },
+	}
+	for name, fn := range self.funcs {
+		funcs[name] = fn
+	}
+	return funcs
+}
+
+// asset returns the URL of the static asset, versioned by its modification time (if exists).
+func (self *Engine) asset(name string) string {
+	url := strings.TrimSuffix(self.Assets, "/") + "/" + strings.TrimPrefix(name, "/")
+	if info, err := os.Stat(filepath.Join(self.AssetsDir, filepath.FromSlash(name))); err == nil {
+		url += "?v=" + strconv.FormatInt(info.ModTime().Unix(), 36)
+	}
+	return url
+}
@@ -0,0 +1,123 @@
+package view
+
+import (
+	"bytes"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/goanywhere/rex"
+	"github.com/goanywhere/rex/middleware"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func setup(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "rex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(filename), os.ModePerm)
+		if err := ioutil.WriteFile(filename, []byte(content), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestEngine(t *testing.T) {
+	dir := setup(t, map[string]string{
+		"views/layouts/base.html":  `<html><head><title>{{block "title" .}}rex{{end}}</title></head><body>{{template "partials/nav.html" .}}{{block "content" .}}{{end}}</body></html>`,
+		"views/layouts/admin.html": `<admin>{{block "content" .}}{{end}}</admin>`,
+		"views/partials/nav.html":  `<nav>{{.User}}</nav>`,
+		"views/index.html":         `{{define "title"}}Home{{end}}{{define "content"}}<p>{{greet .User}}</p>{{end}}`,
+		"views/users/show.html":    `{{/* layout: admin.html */}}{{define "content"}}<a href="{{asset "css/app.css"}}">{{.User}}</a>{{end}}`,
+		"views/raw.html":           `{{/* layout: none */}}<p>{{.User}}</p>`,
+		"views/form.html":          `{{/* layout: none */}}<form>{{xsrfField}}</form>`,
+		"static/css/app.css":       `body {}`,
+	})
+	defer os.RemoveAll(dir)
+
+	engine := New()
+	engine.Dir = filepath.Join(dir, "views")
+	engine.AssetsDir = filepath.Join(dir, "static")
+	engine.Debug = false
+	engine.Funcs(template.FuncMap{
+		"greet": func(name string) string { return "Hello " + name },
+	})
+
+	rendered := func(name string) string {
+		var buffer = new(bytes.Buffer)
+		So(engine.ExecuteTemplate(buffer, name, rex.M{"User": "<rex>"}), ShouldBeNil)
+		return buffer.String()
+	}
+
+	Convey("rex.view.Engine (layouts & partials)", t, func() {
+		So(rendered("index.html"), ShouldEqual, `<html><head><title>Home</title></head><body><nav>&lt;rex&gt;</nav><p>Hello &lt;rex&gt;</p></body></html>`)
+		So(rendered("raw.html"), ShouldEqual, `<p>&lt;rex&gt;</p>`)
+		So(rendered("users/show.html"), ShouldStartWith, `<admin><a href="/static/css/app.css?v=`)
+		// instances are reused, safe to render repeatedly.
+		So(rendered("index.html"), ShouldContainSubstring, "<title>Home</title>")
+
+		So(engine.ExecuteTemplate(new(bytes.Buffer), "missing.html", nil), ShouldNotBeNil)
+	})
+
+	Convey("rex.view.Engine (cache)", t, func() {
+		filename := filepath.Join(dir, "views", "raw.html")
+		ioutil.WriteFile(filename, []byte(`{{/* layout: none */}}<p>changed</p>`), os.ModePerm)
+		future := time.Now().Add(time.Hour)
+		os.Chtimes(filename, future, future)
+		So(rendered("raw.html"), ShouldEqual, `<p>&lt;rex&gt;</p>`)
+
+		Convey("re-parsed on change in debug mode", func() {
+			engine.Debug = true
+			So(rendered("raw.html"), ShouldEqual, `<p>changed</p>`)
+		})
+	})
+
+	Convey("rex.view.Engine (XSRF field)", t, func() {
+		app := rex.New()
+		app.Use(middleware.XSRF)
+		app.Templates(engine)
+		app.Get("/form", func(w http.ResponseWriter, r *http.Request) error {
+			return rex.HTML(w, r, http.StatusOK, "form.html", nil)
+		})
+
+		request, _ := http.NewRequest("GET", "/form", nil)
+		response := httptest.NewRecorder()
+		app.ServeHTTP(response, request)
+		token := response.Header()["X-XSRF-Token"][0]
+		So(response.Body.String(), ShouldEqual, `<form><input type="hidden" name="xsrftoken" value="`+token+`"></form>`)
+
+		// reused instances are bound to the current request only.
+		response = httptest.NewRecorder()
+		app.ServeHTTP(response, request)
+		So(response.Body.String(), ShouldContainSubstring, response.Header()["X-XSRF-Token"][0])
+		So(response.Body.String(), ShouldNotContainSubstring, token)
+		So(rendered("form.html"), ShouldEqual, `<form></form>`)
+	})
+
+	Convey("rex.view.Engine (literal)", t, func() {
+		engine := &Engine{Dir: filepath.Join(dir, "views"), Layout: "base.html"}
+		engine.Funcs(template.FuncMap{
+			"greet": func(name string) string { return "Hi " + name },
+		})
+		var buffer = new(bytes.Buffer)
+		So(engine.ExecuteTemplate(buffer, "index.html", rex.M{"User": "rex"}), ShouldBeNil)
+		So(buffer.String(), ShouldContainSubstring, "<p>Hi rex</p>")
+	})
+
+	Convey("rex.view.Engine (unknown layout)", t, func() {
+		dir := setup(t, map[string]string{"views/index.html": `{{/* layout: missing.html */}}`})
+		defer os.RemoveAll(dir)
+		engine := New()
+		engine.Dir = filepath.Join(dir, "views")
+		So(engine.Load(), ShouldNotBeNil)
+	})
+}